@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// adminTokenHeader carries the admin.token for every admin API request
+const adminTokenHeader = "X-Admin-Token"
+
+// AdminStatus is the run state reported by the admin /status endpoint
+type AdminStatus struct {
+	Total      int    `json:"total"`
+	Processed  int    `json:"processed"`
+	Successful int    `json:"successful"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	Unverified int    `json:"unverified"`
+	Current    string `json:"current,omitempty"`
+	Paused     bool   `json:"paused"`
+	Stopped    bool   `json:"stopped"`
+}
+
+// AdminServer is a small HTTP API to pause, resume, stop and inspect a
+// running campaign. The send loop calls Checkpoint between contacts.
+type AdminServer struct {
+	mu      sync.Mutex
+	token   string
+	paused  bool
+	stopped bool
+	status  AdminStatus
+}
+
+// StartAdminServer listens on addr and serves the admin API in the
+// background. Every request must carry the token in the X-Admin-Token header.
+func StartAdminServer(addr, token string) (*AdminServer, error) {
+	if token == "" {
+		return nil, fmt.Errorf("admin.token must be set to use the admin API")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	admin := &AdminServer{token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", admin.handle(func() {}))
+	mux.HandleFunc("/pause", admin.handle(func() {
+		admin.paused = true
+		automessage.Log("warn", "Run paused via admin API")
+	}))
+	mux.HandleFunc("/resume", admin.handle(func() {
+		admin.paused = false
+		automessage.Log("info", "Run resumed via admin API")
+	}))
+	mux.HandleFunc("/stop", admin.handle(func() {
+		admin.stopped = true
+		automessage.Log("warn", "Run stop requested via admin API, finishing the current contact")
+	}))
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Admin API stopped: %v", err))
+		}
+	}()
+
+	automessage.Log("info", fmt.Sprintf("Admin API listening on %s", listener.Addr()))
+	return admin, nil
+}
+
+// handle wraps an action with token checking and replies with the status.
+// The action runs with the lock held.
+func (a *AdminServer) handle(action func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(adminTokenHeader)), []byte(a.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		a.mu.Lock()
+		action()
+		status := a.snapshot()
+		a.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// snapshot returns the current status. Must be called with a.mu held.
+func (a *AdminServer) snapshot() AdminStatus {
+	status := a.status
+	status.Paused = a.paused
+	status.Stopped = a.stopped
+	return status
+}
+
+// Checkpoint records the loop's progress, blocks while the run is paused and
+// returns false once a stop was requested
+func (a *AdminServer) Checkpoint(status AdminStatus) bool {
+	a.mu.Lock()
+	a.status = status
+	a.mu.Unlock()
+
+	loggedPause := false
+	for {
+		a.mu.Lock()
+		paused, stopped := a.paused, a.stopped
+		a.mu.Unlock()
+
+		if stopped {
+			return false
+		}
+		if !paused {
+			return true
+		}
+		if !loggedPause {
+			automessage.Log("info", "Paused, waiting for /resume or /stop...")
+			loggedPause = true
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
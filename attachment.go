@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AttachmentKind is how a per-contact attachment is sent, detected from the
+// file extension of the attachment column
+type AttachmentKind string
+
+const (
+	AttachmentImage    AttachmentKind = "image"    // Photos & Videos, shown inline
+	AttachmentVideo    AttachmentKind = "video"    // Photos & Videos, shown inline
+	AttachmentDocument AttachmentKind = "document" // Document, sent as a file
+	AttachmentAudio    AttachmentKind = "audio"    // Document, WhatsApp shows a player
+)
+
+// attachmentColumn is the CSV column holding each contact's attachment path
+const attachmentColumn = "attachment"
+
+// attachmentExtensions maps lower-case file extensions to how they are sent.
+// Videos are limited to what the Photos & Videos input accepts.
+var attachmentExtensions = map[string]AttachmentKind{
+	".jpg": AttachmentImage, ".jpeg": AttachmentImage, ".png": AttachmentImage,
+	".gif": AttachmentImage, ".webp": AttachmentImage,
+
+	".mp4": AttachmentVideo, ".3gp": AttachmentVideo, ".mov": AttachmentVideo,
+
+	".pdf": AttachmentDocument, ".doc": AttachmentDocument, ".docx": AttachmentDocument,
+	".xls": AttachmentDocument, ".xlsx": AttachmentDocument, ".csv": AttachmentDocument,
+	".ppt": AttachmentDocument, ".pptx": AttachmentDocument, ".txt": AttachmentDocument,
+	".zip": AttachmentDocument, ".odt": AttachmentDocument, ".ods": AttachmentDocument,
+
+	".mp3": AttachmentAudio, ".ogg": AttachmentAudio, ".opus": AttachmentAudio,
+	".m4a": AttachmentAudio, ".aac": AttachmentAudio, ".wav": AttachmentAudio,
+	".amr": AttachmentAudio,
+}
+
+// attachmentKindFor detects how the file at path is sent; false for
+// extensions WhatsApp can't take as an attachment here
+func attachmentKindFor(path string) (AttachmentKind, bool) {
+	kind, ok := attachmentExtensions[strings.ToLower(filepath.Ext(path))]
+	return kind, ok
+}
+
+// sentAsMedia reports whether the attachment goes through Photos & Videos
+// rather than Document
+func (k AttachmentKind) sentAsMedia() bool {
+	return k == "" || k == AttachmentImage || k == AttachmentVideo
+}
+
+// attachmentBreakdown formats the attachment kinds sent in a run, e.g.
+// "3 document, 2 image"; empty if none were sent
+func attachmentBreakdown(counts map[AttachmentKind]int) string {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[AttachmentKind(kind)], kind))
+	}
+	return strings.Join(parts, ", ")
+}
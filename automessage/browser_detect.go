@@ -0,0 +1,121 @@
+package automessage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Supported Chromium-based browsers, in auto-detection order
+var browserTypes = []string{"chrome", "chromium", "brave", "edge"}
+
+// browserInstall describes where a browser lives on each OS
+type browserInstall struct {
+	windows []string // Paths relative to Program Files / LOCALAPPDATA style roots
+	macApp  string   // Path inside /Applications
+	linux   []string // Executable names looked up in PATH and /usr/bin
+}
+
+var browserInstalls = map[string]browserInstall{
+	"chrome": {
+		windows: []string{`Google\Chrome\Application\chrome.exe`},
+		macApp:  "Google Chrome.app/Contents/MacOS/Google Chrome",
+		linux:   []string{"google-chrome", "google-chrome-stable"},
+	},
+	"chromium": {
+		windows: []string{`Chromium\Application\chrome.exe`},
+		macApp:  "Chromium.app/Contents/MacOS/Chromium",
+		linux:   []string{"chromium", "chromium-browser"},
+	},
+	"brave": {
+		windows: []string{`BraveSoftware\Brave-Browser\Application\brave.exe`},
+		macApp:  "Brave Browser.app/Contents/MacOS/Brave Browser",
+		linux:   []string{"brave-browser", "brave"},
+	},
+	"edge": {
+		windows: []string{`Microsoft\Edge\Application\msedge.exe`},
+		macApp:  "Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+		linux:   []string{"microsoft-edge", "microsoft-edge-stable"},
+	},
+}
+
+// isValidBrowserType reports whether browserType is empty (any) or supported
+func isValidBrowserType(browserType string) bool {
+	if browserType == "" {
+		return true
+	}
+	for _, t := range browserTypes {
+		if t == browserType {
+			return true
+		}
+	}
+	return false
+}
+
+// browserCandidates returns the locations to probe for one browser type on
+// the current OS, in order of preference
+func browserCandidates(browserType string) []string {
+	install := browserInstalls[browserType]
+
+	switch runtime.GOOS {
+	case "windows":
+		roots := []string{
+			`C:\Program Files`,
+			`C:\Program Files (x86)`,
+			os.Getenv("LOCALAPPDATA"),
+		}
+		var paths []string
+		for _, rel := range install.windows {
+			for _, root := range roots {
+				paths = append(paths, root+`\`+rel)
+			}
+		}
+		return paths
+	case "darwin":
+		paths := []string{filepath.Join("/Applications", install.macApp)}
+		if home, err := os.UserHomeDir(); err == nil {
+			paths = append(paths, filepath.Join(home, "Applications", install.macApp))
+		}
+		return paths
+	default:
+		// Linux and other Unix-likes: resolve through PATH first, then
+		// well-known install locations
+		var paths []string
+		for _, name := range install.linux {
+			if path, err := exec.LookPath(name); err == nil {
+				paths = append(paths, path)
+			}
+		}
+		for _, name := range install.linux {
+			paths = append(paths, filepath.Join("/usr/bin", name))
+		}
+		if browserType == "chromium" {
+			paths = append(paths, "/snap/bin/chromium")
+		}
+		return paths
+	}
+}
+
+// FindChromePath attempts to locate a Chromium-based browser executable on
+// the system. If browserType is set only that browser is considered,
+// otherwise all supported browsers are tried in order.
+func FindChromePath(browserType string) string {
+	types := browserTypes
+	if browserType != "" {
+		types = []string{browserType}
+	}
+
+	for _, t := range types {
+		for _, path := range browserCandidates(t) {
+			if _, err := os.Stat(path); err == nil {
+				Log("info", fmt.Sprintf("Auto-detected %s at: %s", t, path))
+				return path
+			}
+		}
+	}
+
+	// Return empty string to use chromedp defaults if not found
+	return ""
+}
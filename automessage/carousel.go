@@ -0,0 +1,73 @@
+package automessage
+
+import (
+	"fmt"
+	"os"
+)
+
+// carouselImage is a files.images entry with its caption template parsed
+type carouselImage struct {
+	Path    string
+	Caption *MessageTemplate // nil sends the image without a caption
+}
+
+// CarouselSend is one image of a contact's carousel with its rendered caption
+type CarouselSend struct {
+	Path    string
+	Caption string
+}
+
+// loadCarousel parses the caption templates of files.images and checks that
+// every image exists, so a missing file stops the run before any send
+func loadCarousel(images []CarouselImage) ([]carouselImage, error) {
+	carousel := make([]carouselImage, 0, len(images))
+	for i, image := range images {
+		if _, err := os.Stat(image.Path); err != nil {
+			return nil, fmt.Errorf("files.images[%d]: image %s not found", i, image.Path)
+		}
+		entry := carouselImage{Path: image.Path}
+		if image.CaptionTemplate != "" {
+			caption, err := NewMessageTemplate(image.CaptionTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("files.images[%d] caption_template: %w", i, err)
+			}
+			entry.Caption = caption
+		}
+		carousel = append(carousel, entry)
+	}
+	return carousel, nil
+}
+
+// RenderCarousel renders each image's caption for a contact. Contacts who
+// opted for text only get no images.
+func RenderCarousel(carousel []carouselImage, contact Contact) ([]CarouselSend, error) {
+	if contact.Media == MediaText || contact.Media == MediaNone {
+		return nil, nil
+	}
+
+	sends := make([]CarouselSend, 0, len(carousel))
+	for i, image := range carousel {
+		send := CarouselSend{Path: image.Path}
+		if image.Caption != nil {
+			caption, err := image.Caption.Render(contact)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render caption of image %d: %w", i+1, err)
+			}
+			send.Caption = caption
+		}
+		sends = append(sends, send)
+	}
+	return sends, nil
+}
+
+// carouselTemplates returns the caption templates of the carousel, for the
+// passes applied to every template
+func carouselTemplates(carousel []carouselImage) []*MessageTemplate {
+	var templates []*MessageTemplate
+	for _, image := range carousel {
+		if image.Caption != nil {
+			templates = append(templates, image.Caption)
+		}
+	}
+	return templates
+}
@@ -0,0 +1,371 @@
+package automessage
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracker key strategies (tracker.key_strategy)
+const (
+	TrackerKeyContent   = "content"    // Phone, name, fields and template: a changed message is sent again
+	TrackerKeyPhoneName = "phone_name" // Phone and name, whatever the template
+	TrackerKeyPhoneOnly = "phone_only" // Never message a number twice
+)
+
+type CompletedContact struct {
+	Name        string
+	PhoneNumber string
+	Hash        string
+	Timestamp   string
+	Variant     string // template.variants name, if the run had variants
+}
+
+// Tracker records which contacts have already been handled so reruns skip them
+type Tracker interface {
+	IsCompleted(contact Contact) bool
+	SentBefore(contact Contact) bool
+	MarkCompleted(contact Contact) error
+	GetCompletedCount() int
+	Close() error
+}
+
+type CompletedTracker struct {
+	mu              sync.Mutex
+	filePath        string
+	completed       map[string]CompletedContact // key: hash
+	phones          map[string]bool             // Clean phone numbers of the completed entries
+	messageTemplate *MessageTemplate            // Template fingerprint and variant go into the hash
+	keyStrategy     string                      // tracker.key_strategy, what goes into the hash
+	variantColumn   bool                        // The CSV has a variant column
+	file            *os.File                    // Opened lazily on first write, kept open until Close
+	writer          *csv.Writer
+}
+
+func NewCompletedTracker(filePath string, messageTemplate *MessageTemplate, keyStrategy string) (*CompletedTracker, error) {
+	tracker := &CompletedTracker{
+		filePath:        filePath,
+		completed:       make(map[string]CompletedContact),
+		phones:          make(map[string]bool),
+		messageTemplate: messageTemplate,
+		keyStrategy:     keyStrategy,
+	}
+
+	// Load existing completed contacts if file exists
+	if err := tracker.load(); err != nil {
+		// If file doesn't exist, that's okay - we'll create it
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load completed contacts: %w", err)
+		}
+	}
+
+	return tracker, nil
+}
+
+// Hash creates a unique hash for a contact. What goes into it
+// depends on the key strategy: phone, name, fields and message template for
+// content, less for phone_name and phone_only.
+func (ct *CompletedTracker) Hash(contact Contact) string {
+	switch ct.keyStrategy {
+	case TrackerKeyPhoneOnly, TrackerKeyPhoneName:
+		// Groups have no number, their name is what identifies them
+		data := "phone:" + CleanPhoneNumber(contact.PhoneNumber)
+		if contact.Type == ContactGroup {
+			data = "group:" + contact.Name
+		} else if ct.keyStrategy == TrackerKeyPhoneName {
+			data += "|name:" + strings.TrimSpace(contact.Name)
+		}
+		hash := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(hash[:])
+	}
+
+	// Start with phone, name, and message template
+	data := fmt.Sprintf("%s|%s|%s", contact.PhoneNumber, contact.Name, ct.messageTemplate.Fingerprint())
+
+	// The A/B variant the contact gets, absent without variants so
+	// existing completed files still match
+	if variant := ct.messageTemplate.VariantFor(contact); variant != "" {
+		data += "|variant:" + variant
+	}
+
+	// Groups have no number, keep them apart from a person with the same
+	// name. Persons hash as before so existing completed files still match.
+	if contact.Type == ContactGroup {
+		data += "|type:group"
+	}
+
+	// Add all additional fields in sorted order for consistency
+	// This ensures the hash is the same regardless of field order
+	keys := make([]string, 0, len(contact.Fields))
+	for key := range contact.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data += fmt.Sprintf("|%s:%s", key, contact.Fields[key])
+	}
+
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+func (ct *CompletedTracker) load() error {
+	file, err := os.Open(ct.filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	// Read all records
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read completed CSV: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil // Empty file
+	}
+
+	// Parse header
+	header := records[0]
+	nameIdx := -1
+	phoneIdx := -1
+	hashIdx := -1
+	timestampIdx := -1
+	variantIdx := -1
+
+	for i, col := range header {
+		col = strings.TrimSpace(strings.ToLower(col))
+		if col == "name" {
+			nameIdx = i
+		} else if col == "phone_number" || col == "phone" {
+			phoneIdx = i
+		} else if col == "hash" {
+			hashIdx = i
+		} else if col == "timestamp" || col == "date" {
+			timestampIdx = i
+		} else if col == "variant" {
+			variantIdx = i
+		}
+	}
+
+	if nameIdx == -1 || phoneIdx == -1 || hashIdx == -1 {
+		return fmt.Errorf("completed CSV must contain 'name', 'phone_number', and 'hash' columns")
+	}
+	ct.variantColumn = variantIdx != -1
+
+	// Parse completed contacts
+	for i := 1; i < len(records); i++ {
+		row := records[i]
+
+		// Skip empty rows
+		if len(row) == 0 || (len(row) > hashIdx && strings.TrimSpace(row[hashIdx]) == "") {
+			continue
+		}
+
+		if len(row) <= hashIdx {
+			continue // Skip malformed rows
+		}
+
+		hash := strings.TrimSpace(row[hashIdx])
+		contact := CompletedContact{
+			Hash: hash,
+		}
+
+		if len(row) > phoneIdx {
+			contact.PhoneNumber = strings.TrimSpace(row[phoneIdx])
+		}
+
+		if len(row) > nameIdx {
+			contact.Name = strings.TrimSpace(row[nameIdx])
+		}
+
+		if timestampIdx != -1 && len(row) > timestampIdx {
+			contact.Timestamp = strings.TrimSpace(row[timestampIdx])
+		}
+
+		if variantIdx != -1 && len(row) > variantIdx {
+			contact.Variant = strings.TrimSpace(row[variantIdx])
+		}
+
+		ct.completed[hash] = contact
+		ct.phones[CleanPhoneNumber(contact.PhoneNumber)] = true
+	}
+
+	Log("info", fmt.Sprintf("Loaded %d completed contacts from %s", len(ct.completed), ct.filePath))
+
+	return nil
+}
+
+func (ct *CompletedTracker) IsCompleted(contact Contact) bool {
+	hash := ct.Hash(contact)
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	_, exists := ct.completed[hash]
+	return exists
+}
+
+// SentBefore reports whether the contact's phone number has an entry,
+// whatever its hash. With the content key strategy, a contact that was sent
+// before but isn't completed has changed since its last send.
+func (ct *CompletedTracker) SentBefore(contact Contact) bool {
+	phone := CleanPhoneNumber(contact.PhoneNumber)
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	return phone != "" && ct.phones[phone]
+}
+
+func (ct *CompletedTracker) MarkCompleted(contact Contact) error {
+	hash := ct.Hash(contact)
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	// Add to in-memory map
+	completedContact := CompletedContact{
+		Name:        contact.Name,
+		PhoneNumber: contact.PhoneNumber,
+		Hash:        hash,
+		Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
+		Variant:     ct.messageTemplate.VariantFor(contact),
+	}
+	ct.completed[hash] = completedContact
+	ct.phones[CleanPhoneNumber(contact.PhoneNumber)] = true
+
+	// Append to CSV file
+	return ct.appendToFile(completedContact)
+}
+
+// openFile opens the completed CSV for appending, writing the header if the
+// file is new or empty. Must be called with ct.mu held.
+func (ct *CompletedTracker) openFile() error {
+	file, err := os.OpenFile(ct.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open completed CSV: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat completed CSV: %w", err)
+	}
+
+	ct.file = file
+	ct.writer = csv.NewWriter(file)
+
+	// Write header if new file. Files of runs with template variants get a
+	// column recording each contact's variant.
+	if info.Size() == 0 {
+		header := []string{"name", "phone_number", "hash", "timestamp"}
+		ct.variantColumn = ct.messageTemplate.HasVariants()
+		if ct.variantColumn {
+			header = append(header, "variant")
+		}
+		if err := ct.writer.Write(header); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// appendToFile writes a record and syncs it to disk so a crash loses nothing
+// that was already marked completed. Must be called with ct.mu held.
+func (ct *CompletedTracker) appendToFile(contact CompletedContact) error {
+	if ct.file == nil {
+		if err := ct.openFile(); err != nil {
+			return err
+		}
+	}
+
+	// Write contact record
+	record := []string{
+		contact.Name,
+		contact.PhoneNumber,
+		contact.Hash,
+		contact.Timestamp,
+	}
+	if ct.variantColumn {
+		record = append(record, contact.Variant)
+	}
+
+	if err := ct.writer.Write(record); err != nil {
+		return fmt.Errorf("failed to write CSV record: %w", err)
+	}
+
+	ct.writer.Flush()
+	if err := ct.writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV record: %w", err)
+	}
+	if err := ct.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync completed CSV: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes any pending records and closes the completed CSV
+func (ct *CompletedTracker) Close() error {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.file == nil {
+		return nil
+	}
+
+	ct.writer.Flush()
+	flushErr := ct.writer.Error()
+	closeErr := ct.file.Close()
+	ct.file = nil
+	ct.writer = nil
+
+	if flushErr != nil {
+		return fmt.Errorf("failed to flush completed CSV: %w", flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close completed CSV: %w", closeErr)
+	}
+	return nil
+}
+
+// Entries returns the completed records loaded or written so far
+func (ct *CompletedTracker) Entries() []CompletedContact {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	entries := make([]CompletedContact, 0, len(ct.completed))
+	for _, entry := range ct.completed {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (ct *CompletedTracker) GetCompletedCount() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	return len(ct.completed)
+}
+
+// NullTracker never reports a contact as completed and records nothing.
+// Used with -no-track for repeated test sends to throwaway numbers.
+type NullTracker struct{}
+
+func (NullTracker) IsCompleted(contact Contact) bool    { return false }
+func (NullTracker) SentBefore(contact Contact) bool     { return false }
+func (NullTracker) MarkCompleted(contact Contact) error { return nil }
+func (NullTracker) GetCompletedCount() int              { return 0 }
+func (NullTracker) Close() error                        { return nil }
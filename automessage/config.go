@@ -0,0 +1,704 @@
+package automessage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Browser       BrowserConfig       `yaml:"browser" json:"browser"`
+	Files         FilesConfig         `yaml:"files" json:"files"`
+	Retry         RetryConfig         `yaml:"retry" json:"retry"`
+	RateLimiting  RateLimitingConfig  `yaml:"rate_limiting" json:"rate_limiting"`
+	Logging       LoggingConfig       `yaml:"logging" json:"logging"`
+	Notifications NotificationsConfig `yaml:"notifications" json:"notifications"`
+	Template      TemplateConfig      `yaml:"template" json:"template"`
+	Admin         AdminConfig         `yaml:"admin" json:"admin"`
+	Tracker       TrackerConfig       `yaml:"tracker" json:"tracker"`
+	Environment   string              `yaml:"environment" json:"environment"`
+}
+
+// Sandbox reports whether the config is for the sandbox environment, where
+// messages are composed in the browser but never sent
+func (c *Config) Sandbox() bool {
+	return c.Environment == "sandbox"
+}
+
+// TrackerConfig decides what makes two sends the same in the completed and
+// unverified trackers
+type TrackerConfig struct {
+	KeyStrategy string `yaml:"key_strategy" json:"key_strategy"` // content (default), phone_name or phone_only
+
+	// Above this share of the list already completed, with no send recorded
+	// since the list changed, ask before running (-1 never)
+	GuardCompletedPercent float64 `yaml:"guard_completed_percent" json:"guard_completed_percent"`
+}
+
+// AdminConfig secures the optional admin HTTP API (-admin-addr)
+type AdminConfig struct {
+	Token string `yaml:"token" json:"token"`
+}
+
+type TemplateConfig struct {
+	OpenerPath        string `yaml:"opener_path" json:"opener_path"`
+	ImageThenText     bool   `yaml:"image_then_text" json:"image_then_text"`
+	CaptionPath       string `yaml:"caption_path" json:"caption_path"`
+	ExpandEmoji       bool   `yaml:"expand_emoji" json:"expand_emoji"`
+	TrimBlankLines    bool   `yaml:"trim_blank_lines" json:"trim_blank_lines"`
+	MessageSeparator  string `yaml:"message_separator" json:"message_separator"`
+	AllowEmptyCaption bool   `yaml:"allow_empty_caption" json:"allow_empty_caption"`
+
+	Variants []TemplateVariant `yaml:"variants" json:"variants"` // A/B test: each contact gets one, replaces files.template_path
+}
+
+// TemplateVariant is one weighted message template of an A/B test
+type TemplateVariant struct {
+	Name   string  `yaml:"name" json:"name"` // Defaults to the file name without extension
+	Path   string  `yaml:"path" json:"path"`
+	Weight float64 `yaml:"weight" json:"weight"`
+}
+
+type BrowserConfig struct {
+	Headless                  bool                  `yaml:"headless" json:"headless"`
+	UserDataDir               string                `yaml:"user_data_dir" json:"user_data_dir"`
+	ProfilesBaseDir           string                `yaml:"profiles_base_dir" json:"profiles_base_dir"`
+	ChromePath                string                `yaml:"chrome_path" json:"chrome_path"`
+	QRTimeoutSeconds          int                   `yaml:"qr_timeout_seconds" json:"qr_timeout_seconds"`
+	PageLoadTimeout           int                   `yaml:"page_load_timeout" json:"page_load_timeout"`
+	ConsoleLog                bool                  `yaml:"console_log" json:"console_log"`
+	SendURLBase               string                `yaml:"send_url_base" json:"send_url_base"`
+	SkipNetworkCheck          bool                  `yaml:"skip_network_check" json:"skip_network_check"`
+	ProxyServer               string                `yaml:"proxy_server" json:"proxy_server"`
+	ClearStrategy             string                `yaml:"clear_strategy" json:"clear_strategy"`
+	WebURL                    string                `yaml:"web_url" json:"web_url"`
+	BrowserType               string                `yaml:"browser_type" json:"browser_type"`
+	OpenChatBy                string                `yaml:"open_chat_by" json:"open_chat_by"`
+	SearchField               string                `yaml:"search_field" json:"search_field"`
+	MaxReinit                 int                   `yaml:"max_reinit" json:"max_reinit"`
+	QuoteLastInbound          bool                  `yaml:"quote_last_inbound" json:"quote_last_inbound"`
+	SuccessCriteria           string                `yaml:"success_criteria" json:"success_criteria"`
+	SuccessTimeouts           SuccessTimeoutsConfig `yaml:"success_timeouts" json:"success_timeouts"`
+	QRMaxExtensions           int                   `yaml:"qr_max_extensions" json:"qr_max_extensions"`
+	PreSendDelayMs            int                   `yaml:"pre_send_delay_ms" json:"pre_send_delay_ms"`
+	PreSendWaitSeconds        int                   `yaml:"pre_send_wait_seconds" json:"pre_send_wait_seconds"`
+	ReloadEveryN              int                   `yaml:"reload_every_n" json:"reload_every_n"`
+	ReloadMode                string                `yaml:"reload_mode" json:"reload_mode"`
+	SkipIfAlreadyInChat       bool                  `yaml:"skip_if_already_in_chat" json:"skip_if_already_in_chat"`
+	AlreadyInChatScan         int                   `yaml:"already_in_chat_scan" json:"already_in_chat_scan"`
+	VerifyRecipient           bool                  `yaml:"verify_recipient" json:"verify_recipient"`
+	ScrollToBottom            bool                  `yaml:"scroll_to_bottom" json:"scroll_to_bottom"`
+	ScrollToBottomCount       int                   `yaml:"scroll_to_bottom_count" json:"scroll_to_bottom_count"`
+	AutoReloadOnUpdate        bool                  `yaml:"auto_reload_on_update" json:"auto_reload_on_update"`
+	ImageSendVia              string                `yaml:"image_send_via" json:"image_send_via"`
+	VerifySelectors           []string              `yaml:"verify_selectors" json:"verify_selectors"`
+	TypingDwellMs             int                   `yaml:"typing_dwell_ms" json:"typing_dwell_ms"`
+	VideoUploadTimeoutSeconds int                   `yaml:"video_upload_timeout_seconds" json:"video_upload_timeout_seconds"`
+}
+
+// SuccessTimeoutsConfig is how long to wait for each success criterion, in
+// seconds
+type SuccessTimeoutsConfig struct {
+	Bubble    int `yaml:"bubble" json:"bubble"`
+	Sent      int `yaml:"sent" json:"sent"`
+	Delivered int `yaml:"delivered" json:"delivered"`
+}
+
+type FilesConfig struct {
+	CSVPath                 string               `yaml:"csv_path" json:"csv_path"`
+	TemplatePath            string               `yaml:"template_path" json:"template_path"`
+	CompletedCSVPath        string               `yaml:"completed_csv_path" json:"completed_csv_path"`
+	UnverifiedCSVPath       string               `yaml:"unverified_csv_path" json:"unverified_csv_path"`
+	ImagePath               string               `yaml:"image_path" json:"image_path"`
+	VideoPath               string               `yaml:"video_path" json:"video_path"`
+	NameColumns             []string             `yaml:"name_columns" json:"name_columns"`
+	PhoneColumns            []string             `yaml:"phone_columns" json:"phone_columns"`
+	RequireName             bool                 `yaml:"require_name" json:"require_name"`
+	TemplateVars            string               `yaml:"template_vars" json:"template_vars"`
+	TemplateVarsPrecedence  string               `yaml:"template_vars_precedence" json:"template_vars_precedence"`
+	ImagePathTemplate       string               `yaml:"image_path_template" json:"image_path_template"`
+	NationalToInternational NationalNumberConfig `yaml:"national_to_international" json:"national_to_international"`
+	OutputDir               string               `yaml:"output_dir" json:"output_dir"`
+	TrackersInOutputDir     bool                 `yaml:"trackers_in_output_dir" json:"trackers_in_output_dir"`
+	MessageColumn           string               `yaml:"message_column" json:"message_column"`
+	Schema                  CSVSchema            `yaml:"schema" json:"schema"`
+	ExcludeCSV              string               `yaml:"exclude_csv" json:"exclude_csv"`
+	Transforms              map[string][]string  `yaml:"transforms" json:"transforms"`
+	CanaryPhone             string               `yaml:"canary_phone" json:"canary_phone"`
+	CanaryName              string               `yaml:"canary_name" json:"canary_name"`
+	CanaryFields            map[string]string    `yaml:"canary_fields" json:"canary_fields"`
+	CanaryConfirm           bool                 `yaml:"canary_confirm" json:"canary_confirm"`
+	VCardPhoneTypes         []string             `yaml:"vcard_phone_types" json:"vcard_phone_types"`
+	RunStatsPath            string               `yaml:"run_stats_path" json:"run_stats_path"`
+	BaseTemplatePath        string               `yaml:"base_template_path" json:"base_template_path"`
+	OnBadRow                string               `yaml:"on_bad_row" json:"on_bad_row"`
+	BadRowsCSV              string               `yaml:"bad_rows_csv" json:"bad_rows_csv"`
+	Images                  []CarouselImage      `yaml:"images" json:"images"`
+}
+
+// CarouselImage is one image of files.images, sent to every contact as its
+// own message with its own caption
+type CarouselImage struct {
+	Path            string `yaml:"path" json:"path"`
+	CaptionTemplate string `yaml:"caption_template" json:"caption_template"` // Optional, rendered per contact
+}
+
+// CSVSchema declares the columns the contacts CSV is expected to have
+type CSVSchema struct {
+	Required   []string `yaml:"required" json:"required"`
+	Optional   []string `yaml:"optional" json:"optional"`
+	Unexpected string   `yaml:"unexpected" json:"unexpected"` // warn or error on columns not listed
+}
+
+// NationalNumberConfig converts numbers stored in national format (e.g.
+// "054-1234567") to international format. Empty CountryCode disables it.
+type NationalNumberConfig struct {
+	CountryCode      string `yaml:"country_code" json:"country_code"`             // e.g. "972" for Israel
+	StripLeadingZero bool   `yaml:"strip_leading_zero" json:"strip_leading_zero"` // Drop the national trunk 0 before prefixing
+}
+
+type RetryConfig struct {
+	MaxRetries              int      `yaml:"max_retries" json:"max_retries"`
+	InitialDelaySeconds     int      `yaml:"initial_delay_seconds" json:"initial_delay_seconds"`
+	MaxDelaySeconds         int      `yaml:"max_delay_seconds" json:"max_delay_seconds"`
+	BackoffMultiplier       float64  `yaml:"backoff_multiplier" json:"backoff_multiplier"`
+	ResendUnverified        bool     `yaml:"resend_unverified" json:"resend_unverified"`
+	Escalate                bool     `yaml:"escalate" json:"escalate"`
+	ThrottleMaxDelaySeconds int      `yaml:"throttle_max_delay_seconds" json:"throttle_max_delay_seconds"`
+	RetryOnPatterns         []string `yaml:"retry_on_patterns" json:"retry_on_patterns"`
+	AbortOnPatterns         []string `yaml:"abort_on_patterns" json:"abort_on_patterns"`
+}
+
+type RateLimitingConfig struct {
+	MessagesPerSecond    int                       `yaml:"messages_per_second" json:"messages_per_second"`
+	Enabled              bool                      `yaml:"enabled" json:"enabled"`
+	BanPendingThreshold  int                       `yaml:"ban_pending_threshold" json:"ban_pending_threshold"`
+	BanCooldownMinutes   int                       `yaml:"ban_cooldown_minutes" json:"ban_cooldown_minutes"`
+	DeliveryHealth       DeliveryHealthConfig      `yaml:"delivery_health" json:"delivery_health"`
+	Ramp                 RampConfig                `yaml:"ramp" json:"ramp"`
+	BatchSize            int                       `yaml:"batch_size" json:"batch_size"`
+	BatchCooldownMinutes float64                   `yaml:"batch_cooldown_minutes" json:"batch_cooldown_minutes"`
+	Priorities           map[string]PriorityConfig `yaml:"priorities" json:"priorities"`
+	WarnOverHours        float64                   `yaml:"warn_over_hours" json:"warn_over_hours"`
+	ConfirmOverHours     float64                   `yaml:"confirm_over_hours" json:"confirm_over_hours"`
+}
+
+// DeliveryHealthConfig pauses the run when too many of the recent sends
+// were not delivered
+type DeliveryHealthConfig struct {
+	Window                int     `yaml:"window" json:"window"` // Sends to look back over, 0 = off
+	MaxUndeliveredPercent float64 `yaml:"max_undelivered_percent" json:"max_undelivered_percent"`
+	PauseMinutes          int     `yaml:"pause_minutes" json:"pause_minutes"`
+}
+
+// PriorityConfig adjusts pacing and verification for contacts whose
+// priority column has this value
+type PriorityConfig struct {
+	ExtraDelaySeconds float64 `yaml:"extra_delay_seconds" json:"extra_delay_seconds"`
+	SuccessCriteria   string  `yaml:"success_criteria" json:"success_criteria"` // Empty uses browser.success_criteria
+}
+
+// ErrInvalidPriority is returned for a contact whose priority column names
+// no entry in rate_limiting.priorities. It is never retried.
+var ErrInvalidPriority = errors.New("priority is not configured in rate_limiting.priorities")
+
+// ContactPriority returns the name and settings of the contact's priority.
+// The priority column is only read when rate_limiting.priorities is set, so
+// a CSV that happens to have one doesn't fail every row of a plain run.
+func ContactPriority(config *Config, contact Contact) (string, PriorityConfig, error) {
+	if len(config.RateLimiting.Priorities) == 0 {
+		return "normal", PriorityConfig{}, nil
+	}
+	name := strings.ToLower(strings.TrimSpace(contact.FieldValue("priority")))
+	if name == "" {
+		name = "normal"
+	}
+	priority, ok := config.RateLimiting.Priorities[name]
+	if !ok && name != "normal" {
+		return name, PriorityConfig{}, fmt.Errorf("%w: %q", ErrInvalidPriority, name)
+	}
+	return name, priority, nil
+}
+
+// RampConfig starts a run slowly and speeds up toward the target delay
+// over the first Messages sends
+type RampConfig struct {
+	Enabled             bool    `yaml:"enabled" json:"enabled"`
+	InitialDelaySeconds float64 `yaml:"initial_delay_seconds" json:"initial_delay_seconds"`
+	TargetDelaySeconds  float64 `yaml:"target_delay_seconds" json:"target_delay_seconds"`
+	Messages            int     `yaml:"messages" json:"messages"`
+	Curve               string  `yaml:"curve" json:"curve"` // linear or ease_out
+}
+
+type LoggingConfig struct {
+	Level         string `yaml:"level" json:"level"`
+	OutputFile    string `yaml:"output_file" json:"output_file"`
+	ProgressEvery int    `yaml:"progress_every" json:"progress_every"`
+}
+
+type NotificationsConfig struct {
+	OnComplete    OnCompleteConfig `yaml:"on_complete" json:"on_complete"`
+	SummaryToSelf bool             `yaml:"summary_to_self" json:"summary_to_self"`
+	SelfPhone     string           `yaml:"self_phone" json:"self_phone"`
+}
+
+type OnCompleteConfig struct {
+	SlackWebhookURL string      `yaml:"slack_webhook_url" json:"slack_webhook_url"`
+	Email           EmailConfig `yaml:"email" json:"email"`
+	TimeoutSeconds  int         `yaml:"timeout_seconds" json:"timeout_seconds"`
+}
+
+type EmailConfig struct {
+	SMTPHost string   `yaml:"smtp_host" json:"smtp_host"`
+	SMTPPort int      `yaml:"smtp_port" json:"smtp_port"`
+	Username string   `yaml:"username" json:"username"`
+	Password string   `yaml:"password" json:"password"`
+	From     string   `yaml:"from" json:"from"`
+	To       []string `yaml:"to" json:"to"`
+}
+
+// LoadConfig reads the config file, applies the -set overrides and the
+// template's front-matter, then fills in defaults and validates the result
+func LoadConfig(configPath string, overrides []string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON configs (from infra tooling) use the json tags, anything else is YAML
+	var config Config
+	if strings.EqualFold(filepath.Ext(configPath), ".json") {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON config file: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Command-line overrides win over the file and the front-matter. They
+	// go in first too, so an overridden template_path is the one whose
+	// front-matter is read.
+	if err := applyConfigOverrides(&config, overrides); err != nil {
+		return nil, err
+	}
+
+	// Settings from the message template's own front-matter
+	if err := applyTemplateFrontMatter(&config); err != nil {
+		return nil, err
+	}
+	if err := applyConfigOverrides(&config, overrides); err != nil {
+		return nil, err
+	}
+
+	// Set defaults if not specified
+	if config.Environment == "" {
+		config.Environment = "production"
+	}
+	if config.Environment != "production" && config.Environment != "sandbox" {
+		return nil, fmt.Errorf("invalid environment %q: must be 'production' or 'sandbox'", config.Environment)
+	}
+
+	if config.Browser.UserDataDir == "" {
+		config.Browser.UserDataDir = "./chrome-data"
+	}
+
+	// Convert user data directory to absolute path
+	if config.Browser.UserDataDir != "" {
+		absPath, err := filepath.Abs(config.Browser.UserDataDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve user data directory path: %w", err)
+		}
+		config.Browser.UserDataDir = absPath
+	}
+
+	if config.Browser.ProfilesBaseDir == "" {
+		config.Browser.ProfilesBaseDir = "./profiles"
+	}
+
+	config.Browser.BrowserType = strings.ToLower(config.Browser.BrowserType)
+	if !isValidBrowserType(config.Browser.BrowserType) {
+		return nil, fmt.Errorf("invalid browser.browser_type %q: must be one of %s",
+			config.Browser.BrowserType, strings.Join(browserTypes, ", "))
+	}
+	if config.Browser.QRTimeoutSeconds == 0 {
+		config.Browser.QRTimeoutSeconds = 60
+	}
+	if config.Browser.PageLoadTimeout == 0 {
+		config.Browser.PageLoadTimeout = 30
+	}
+	if config.Browser.WebURL == "" {
+		config.Browser.WebURL = "https://web.whatsapp.com"
+	}
+	if err := validateURL(config.Browser.WebURL); err != nil {
+		return nil, fmt.Errorf("invalid browser.web_url: %w", err)
+	}
+	if config.Browser.SendURLBase == "" {
+		config.Browser.SendURLBase = "https://web.whatsapp.com/send"
+	}
+	if err := validateURL(config.Browser.SendURLBase); err != nil {
+		return nil, fmt.Errorf("invalid browser.send_url_base: %w", err)
+	}
+	if config.Browser.ClearStrategy == "" {
+		config.Browser.ClearStrategy = "dom"
+	}
+	if config.Browser.ClearStrategy != "dom" && config.Browser.ClearStrategy != "keyboard" {
+		return nil, fmt.Errorf("invalid browser.clear_strategy %q: must be 'dom' or 'keyboard'", config.Browser.ClearStrategy)
+	}
+	if config.Template.ImageThenText && config.Template.CaptionPath == "" {
+		return nil, fmt.Errorf("template.image_then_text requires template.caption_path")
+	}
+	if config.Browser.SuccessCriteria == "" {
+		config.Browser.SuccessCriteria = "bubble"
+	}
+	switch config.Browser.SuccessCriteria {
+	case "bubble", "sent", "delivered":
+	default:
+		return nil, fmt.Errorf("invalid browser.success_criteria %q: must be 'bubble', 'sent' or 'delivered'", config.Browser.SuccessCriteria)
+	}
+	if config.Browser.SuccessTimeouts.Bubble == 0 {
+		config.Browser.SuccessTimeouts.Bubble = 20
+	}
+	if config.Browser.SuccessTimeouts.Sent == 0 {
+		config.Browser.SuccessTimeouts.Sent = 30
+	}
+	if config.Browser.SuccessTimeouts.Delivered == 0 {
+		config.Browser.SuccessTimeouts.Delivered = 120
+	}
+	if config.Browser.QRMaxExtensions == 0 {
+		config.Browser.QRMaxExtensions = 3
+	}
+	if config.Browser.PreSendDelayMs == 0 {
+		config.Browser.PreSendDelayMs = 300
+	}
+	if config.Browser.PreSendWaitSeconds == 0 {
+		config.Browser.PreSendWaitSeconds = 5
+	}
+	if config.Browser.MaxReinit == 0 {
+		config.Browser.MaxReinit = 3
+	}
+	if config.Browser.ImageSendVia == "" {
+		config.Browser.ImageSendVia = "enter"
+	}
+	if config.Browser.ImageSendVia != "enter" && config.Browser.ImageSendVia != "click" {
+		return nil, fmt.Errorf("invalid browser.image_send_via %q: must be 'enter' or 'click'", config.Browser.ImageSendVia)
+	}
+	if len(config.Browser.VerifySelectors) == 0 {
+		config.Browser.VerifySelectors = []string{"div[data-pre-plain-text]"}
+	}
+	if config.Browser.VideoUploadTimeoutSeconds == 0 {
+		config.Browser.VideoUploadTimeoutSeconds = 120
+	}
+	if config.Browser.VideoUploadTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("browser.video_upload_timeout_seconds must be positive")
+	}
+	if config.Browser.TypingDwellMs < 0 {
+		return nil, fmt.Errorf("browser.typing_dwell_ms must not be negative")
+	}
+	if config.Browser.ScrollToBottomCount == 0 {
+		config.Browser.ScrollToBottomCount = 2
+	}
+	if config.Browser.ScrollToBottomCount < 0 {
+		return nil, fmt.Errorf("browser.scroll_to_bottom_count must not be negative")
+	}
+	if config.Browser.AlreadyInChatScan == 0 {
+		config.Browser.AlreadyInChatScan = 20
+	}
+	if config.Browser.ReloadMode == "" {
+		config.Browser.ReloadMode = "blank"
+	}
+	if config.Browser.ReloadMode != "blank" && config.Browser.ReloadMode != "restart" {
+		return nil, fmt.Errorf("invalid browser.reload_mode %q: must be 'blank' or 'restart'", config.Browser.ReloadMode)
+	}
+	if config.Browser.ReloadEveryN < 0 {
+		return nil, fmt.Errorf("invalid browser.reload_every_n %d: must be 0 (off) or positive", config.Browser.ReloadEveryN)
+	}
+	if config.Browser.OpenChatBy == "" {
+		config.Browser.OpenChatBy = "url"
+	}
+	if config.Browser.OpenChatBy != "url" && config.Browser.OpenChatBy != "search" {
+		return nil, fmt.Errorf("invalid browser.open_chat_by %q: must be 'url' or 'search'", config.Browser.OpenChatBy)
+	}
+	if config.Browser.SearchField == "" {
+		config.Browser.SearchField = "name"
+	}
+	if err := validateTransforms(config.Files.Transforms); err != nil {
+		return nil, fmt.Errorf("invalid files.transforms: %w", err)
+	}
+	if config.Files.Schema.Unexpected == "" {
+		config.Files.Schema.Unexpected = "warn"
+	}
+	if config.Files.Schema.Unexpected != "warn" && config.Files.Schema.Unexpected != "error" {
+		return nil, fmt.Errorf("invalid files.schema.unexpected %q: must be 'warn' or 'error'", config.Files.Schema.Unexpected)
+	}
+	if config.Files.MessageColumn != "" && config.Files.TemplatePath != "" {
+		return nil, fmt.Errorf("files.template_path and files.message_column are mutually exclusive, set only one")
+	}
+	if config.Files.MessageColumn != "" && config.Files.BaseTemplatePath != "" {
+		return nil, fmt.Errorf("files.base_template_path needs a template_path, it can't wrap files.message_column")
+	}
+	if len(config.Template.Variants) > 0 {
+		if config.Files.TemplatePath != "" || config.Files.MessageColumn != "" {
+			return nil, fmt.Errorf("template.variants replaces files.template_path and files.message_column, leave those unset")
+		}
+		seen := make(map[string]bool)
+		for i := range config.Template.Variants {
+			variant := &config.Template.Variants[i]
+			if strings.TrimSpace(variant.Path) == "" {
+				return nil, fmt.Errorf("template.variants[%d] has no path", i)
+			}
+			if variant.Weight <= 0 {
+				return nil, fmt.Errorf("template.variants[%d] (%s) needs a positive weight", i, variant.Path)
+			}
+			if variant.Name == "" {
+				variant.Name = variantName(variant.Path)
+			}
+			if seen[variant.Name] {
+				return nil, fmt.Errorf("template.variants has two variants named %q, give them distinct names", variant.Name)
+			}
+			seen[variant.Name] = true
+		}
+	}
+	if config.Files.CompletedCSVPath == "" {
+		config.Files.CompletedCSVPath = "completed.csv"
+	}
+	if config.Files.UnverifiedCSVPath == "" {
+		config.Files.UnverifiedCSVPath = "unverified.csv"
+	}
+	if config.Files.VideoPath != "" && (config.Files.ImagePath != "" || config.Files.ImagePathTemplate != "") {
+		return nil, fmt.Errorf("files.video_path replaces files.image_path and files.image_path_template, set only one of them")
+	}
+	if len(config.Files.Images) > 0 {
+		if config.Files.ImagePath != "" || config.Files.ImagePathTemplate != "" || config.Files.VideoPath != "" || config.Template.ImageThenText {
+			return nil, fmt.Errorf("files.images replaces files.image_path, files.video_path, files.image_path_template and template.image_then_text, leave those unset")
+		}
+		for i, image := range config.Files.Images {
+			if strings.TrimSpace(image.Path) == "" {
+				return nil, fmt.Errorf("files.images[%d] has no path", i)
+			}
+		}
+	}
+	if config.Files.OnBadRow == "" {
+		config.Files.OnBadRow = "skip"
+	}
+	if config.Files.OnBadRow != "skip" && config.Files.OnBadRow != "abort" {
+		return nil, fmt.Errorf("invalid files.on_bad_row %q: must be 'skip' or 'abort'", config.Files.OnBadRow)
+	}
+	if config.Files.RunStatsPath == "" {
+		config.Files.RunStatsPath = "run_stats.json"
+	}
+	// Per-day tracker files, resolved once at startup
+	now := time.Now()
+	config.Files.CompletedCSVPath = expandDatePlaceholders(config.Files.CompletedCSVPath, now)
+	config.Files.UnverifiedCSVPath = expandDatePlaceholders(config.Files.UnverifiedCSVPath, now)
+	// Collect the run's artifacts under files.output_dir when set
+	if config.Files.OutputDir != "" {
+		config.Logging.OutputFile = config.OutputPath(config.Logging.OutputFile)
+		config.Files.BadRowsCSV = config.OutputPath(config.Files.BadRowsCSV)
+		if config.Files.TrackersInOutputDir {
+			config.Files.CompletedCSVPath = config.OutputPath(config.Files.CompletedCSVPath)
+			config.Files.UnverifiedCSVPath = config.OutputPath(config.Files.UnverifiedCSVPath)
+			config.Files.RunStatsPath = config.OutputPath(config.Files.RunStatsPath)
+		}
+	}
+	if len(config.Files.NameColumns) == 0 {
+		config.Files.NameColumns = []string{"name"}
+	}
+	if len(config.Files.PhoneColumns) == 0 {
+		config.Files.PhoneColumns = []string{"phone_number", "phone"}
+	}
+	if len(config.Files.VCardPhoneTypes) == 0 {
+		config.Files.VCardPhoneTypes = []string{"cell", "mobile"}
+	}
+	if config.Files.TemplateVarsPrecedence == "" {
+		config.Files.TemplateVarsPrecedence = "contact"
+	}
+	if config.Files.TemplateVarsPrecedence != "contact" && config.Files.TemplateVarsPrecedence != "global" {
+		return nil, fmt.Errorf("invalid files.template_vars_precedence %q: must be 'contact' or 'global'", config.Files.TemplateVarsPrecedence)
+	}
+	national := &config.Files.NationalToInternational
+	national.CountryCode = strings.TrimPrefix(strings.TrimSpace(national.CountryCode), "+")
+	for _, r := range national.CountryCode {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid files.national_to_international.country_code %q: must be digits only", national.CountryCode)
+		}
+	}
+	if _, err := CompilePatterns("retry.retry_on_patterns", config.Retry.RetryOnPatterns); err != nil {
+		return nil, err
+	}
+	if _, err := CompilePatterns("retry.abort_on_patterns", config.Retry.AbortOnPatterns); err != nil {
+		return nil, err
+	}
+	if config.Retry.ThrottleMaxDelaySeconds == 0 {
+		config.Retry.ThrottleMaxDelaySeconds = 600
+	}
+	if config.RateLimiting.BanPendingThreshold == 0 {
+		config.RateLimiting.BanPendingThreshold = 3
+	}
+	if config.RateLimiting.BanCooldownMinutes == 0 {
+		config.RateLimiting.BanCooldownMinutes = 15
+	}
+	health := &config.RateLimiting.DeliveryHealth
+	if health.Window < 0 {
+		return nil, fmt.Errorf("rate_limiting.delivery_health.window must not be negative")
+	}
+	if health.MaxUndeliveredPercent == 0 {
+		health.MaxUndeliveredPercent = 50
+	}
+	if health.MaxUndeliveredPercent < 0 || health.MaxUndeliveredPercent > 100 {
+		return nil, fmt.Errorf("rate_limiting.delivery_health.max_undelivered_percent must be between 0 and 100")
+	}
+	if health.PauseMinutes == 0 {
+		health.PauseMinutes = 30
+	}
+	if health.PauseMinutes < 0 {
+		return nil, fmt.Errorf("rate_limiting.delivery_health.pause_minutes must be positive")
+	}
+	if config.RateLimiting.BatchSize < 0 || config.RateLimiting.BatchCooldownMinutes < 0 {
+		return nil, fmt.Errorf("rate_limiting.batch_size and batch_cooldown_minutes must not be negative")
+	}
+	if config.RateLimiting.WarnOverHours == 0 {
+		config.RateLimiting.WarnOverHours = 4
+	}
+	if config.RateLimiting.ConfirmOverHours < 0 {
+		return nil, fmt.Errorf("rate_limiting.confirm_over_hours must not be negative")
+	}
+	priorities := make(map[string]PriorityConfig, len(config.RateLimiting.Priorities))
+	for name, priority := range config.RateLimiting.Priorities {
+		if priority.ExtraDelaySeconds < 0 {
+			return nil, fmt.Errorf("rate_limiting.priorities.%s.extra_delay_seconds must not be negative", name)
+		}
+		switch priority.SuccessCriteria {
+		case "", "bubble", "sent", "delivered":
+		default:
+			return nil, fmt.Errorf("invalid rate_limiting.priorities.%s.success_criteria %q: must be 'bubble', 'sent' or 'delivered'", name, priority.SuccessCriteria)
+		}
+		priorities[strings.ToLower(name)] = priority
+	}
+	config.RateLimiting.Priorities = priorities
+	if config.RateLimiting.Ramp.Curve == "" {
+		config.RateLimiting.Ramp.Curve = "linear"
+	}
+	if config.RateLimiting.Ramp.Enabled {
+		ramp := config.RateLimiting.Ramp
+		if ramp.Curve != "linear" && ramp.Curve != "ease_out" {
+			return nil, fmt.Errorf("invalid rate_limiting.ramp.curve %q: must be 'linear' or 'ease_out'", ramp.Curve)
+		}
+		if ramp.Messages <= 0 {
+			return nil, fmt.Errorf("rate_limiting.ramp.messages must be positive when the ramp is enabled")
+		}
+		if ramp.InitialDelaySeconds < 0 || ramp.TargetDelaySeconds < 0 {
+			return nil, fmt.Errorf("rate_limiting.ramp delays must not be negative")
+		}
+	}
+	if config.Tracker.KeyStrategy == "" {
+		config.Tracker.KeyStrategy = TrackerKeyContent
+	}
+	switch config.Tracker.KeyStrategy {
+	case TrackerKeyContent, TrackerKeyPhoneName, TrackerKeyPhoneOnly:
+	default:
+		return nil, fmt.Errorf("invalid tracker.key_strategy %q: must be content, phone_name or phone_only", config.Tracker.KeyStrategy)
+	}
+	if config.Tracker.GuardCompletedPercent == 0 {
+		config.Tracker.GuardCompletedPercent = 80
+	}
+	if g := config.Tracker.GuardCompletedPercent; g != -1 && (g < 0 || g > 100) {
+		return nil, fmt.Errorf("tracker.guard_completed_percent must be between 0 and 100, or -1 to disable")
+	}
+	if config.Notifications.OnComplete.TimeoutSeconds == 0 {
+		config.Notifications.OnComplete.TimeoutSeconds = 10
+	}
+	if config.Notifications.OnComplete.Email.SMTPPort == 0 {
+		config.Notifications.OnComplete.Email.SMTPPort = 587
+	}
+
+	return &config, nil
+}
+
+// UseProfile switches the browser session to the named profile under
+// browser.profiles_base_dir, overriding user_data_dir
+func (c *Config) UseProfile(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+
+	absPath, err := filepath.Abs(filepath.Join(c.Browser.ProfilesBaseDir, name))
+	if err != nil {
+		return fmt.Errorf("failed to resolve profile directory: %w", err)
+	}
+	c.Browser.UserDataDir = absPath
+
+	return nil
+}
+
+// OutputPath resolves a relative artifact path against files.output_dir.
+// Absolute paths, empty paths and configs without an output directory are
+// returned unchanged.
+func (c *Config) OutputPath(path string) string {
+	if c.Files.OutputDir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.Files.OutputDir, path)
+}
+
+// ListProfiles returns the names of the existing profiles in baseDir
+func ListProfiles(baseDir string) ([]string, error) {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	return profiles, nil
+}
+
+// validateURL checks that a configured URL is absolute with a scheme and host
+func validateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("URL %q must use http or https", rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("URL %q has no host", rawURL)
+	}
+	if parsed.RawQuery != "" {
+		return fmt.Errorf("URL %q must not include a query string, the phone parameter is added automatically", rawURL)
+	}
+	return nil
+}
+
+// datePlaceholders are the placeholders allowed in tracker paths, with the
+// time layout each one is replaced by
+var datePlaceholders = []struct{ placeholder, layout string }{
+	{"{date}", "2006-01-02"},
+	{"{year}", "2006"},
+	{"{month}", "01"},
+	{"{day}", "02"},
+}
+
+// expandDatePlaceholders replaces {date}, {year}, {month} and {day} in a
+// path with the given time, e.g. "completed-{date}.csv" ->
+// "completed-2024-06-01.csv"
+func expandDatePlaceholders(path string, now time.Time) string {
+	for _, p := range datePlaceholders {
+		path = strings.ReplaceAll(path, p.placeholder, now.Format(p.layout))
+	}
+	return path
+}
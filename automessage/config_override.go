@@ -0,0 +1,131 @@
+package automessage
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigOverrides collects repeated -set key=value flags
+type ConfigOverrides []string
+
+func (o *ConfigOverrides) String() string {
+	return strings.Join(*o, ", ")
+}
+
+func (o *ConfigOverrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// applyConfigOverrides sets each "dotted.key=value" override on the config.
+// Keys are the yaml names, e.g. browser.headless or
+// rate_limiting.priorities.vip.extra_delay_seconds, and values are coerced
+// to the field's type. Lists take comma-separated values.
+func applyConfigOverrides(config *Config, overrides []string) error {
+	for _, override := range overrides {
+		key, value, found := strings.Cut(override, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return fmt.Errorf("invalid -set %q: expected key=value", override)
+		}
+		if err := setConfigValue(reflect.ValueOf(config).Elem(), strings.Split(key, "."), value); err != nil {
+			return fmt.Errorf("invalid -set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// setConfigValue walks path down from v, matching struct fields by their
+// yaml tag and map entries by key, and sets the value at the end
+func setConfigValue(v reflect.Value, path []string, value string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		return setScalar(v, value)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == path[0] {
+				return setConfigValue(v.Field(i), path[1:], value)
+			}
+		}
+		return fmt.Errorf("unknown config key %q", path[0])
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%q can't be set with -set", path[0])
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		// Map entries aren't addressable, change a copy and store it back
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		entry := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			entry.Set(existing)
+		}
+		if err := setConfigValue(entry, path[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, entry)
+		return nil
+	default:
+		return fmt.Errorf("%q is not a config section", path[0])
+	}
+}
+
+// setScalar coerces a command-line value to a bool, number, string or list
+// of strings
+func setScalar(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean (use true or false)", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("lists of %s can't be set with -set, edit the config file", v.Type().Elem())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		v.Set(list)
+	case reflect.Struct, reflect.Map:
+		return fmt.Errorf("this is a config section, set one of its keys instead")
+	default:
+		return fmt.Errorf("values of type %s can't be set with -set", v.Type())
+	}
+	return nil
+}
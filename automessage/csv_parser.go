@@ -0,0 +1,516 @@
+package automessage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MediaPreference is a per-contact override of the global image setting,
+// taken from the optional "media" CSV column
+type MediaPreference string
+
+const (
+	MediaDefault MediaPreference = ""      // Follow the global image setting
+	MediaImage   MediaPreference = "image" // Send the image if one is configured
+	MediaText    MediaPreference = "text"  // Text only, even if an image is configured
+	MediaNone    MediaPreference = "none"  // No media at all
+)
+
+// ContactType says how a contact's chat is opened, taken from the optional
+// "type" CSV column
+type ContactType string
+
+const (
+	ContactPerson ContactType = "person" // Chat opened by phone number
+	ContactGroup  ContactType = "group"  // Chat opened by searching for the name
+)
+
+type Contact struct {
+	Name        string
+	PhoneNumber string // Empty for groups
+	Type        ContactType
+	Media       MediaPreference
+	MaxRetries  *int              // From the optional max_retries column, nil uses retry.max_retries
+	Fields      map[string]string // Dynamic fields from CSV
+}
+
+// ChatLabel identifies the contact's chat in logs: the phone number, or the
+// quoted name for groups
+func (c Contact) ChatLabel() string {
+	if c.Type == ContactGroup {
+		return fmt.Sprintf("group %q", c.Name)
+	}
+	return c.PhoneNumber
+}
+
+// FieldValue returns the contact's value for a column name: "name" and
+// "phone" give the contact's name and number, anything else the matching
+// CSV field
+func (c Contact) FieldValue(column string) string {
+	switch strings.ToLower(column) {
+	case "name":
+		return c.Name
+	case "phone", "phone_number":
+		return c.PhoneNumber
+	}
+	for key, value := range c.Fields {
+		if strings.EqualFold(key, column) {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseMaxRetries reads a value from the max_retries column. Empty or
+// invalid values return nil, so the configured retry count applies.
+func parseMaxRetries(value string, row int) *int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		Log("warn", fmt.Sprintf("Row %d: invalid max_retries %q (must be a non-negative integer), using the configured retry count", row, value))
+		return nil
+	}
+	return &n
+}
+
+// parseContactType validates a value from the type column, empty meaning
+// a person
+func parseContactType(value string) (ContactType, error) {
+	switch t := ContactType(strings.ToLower(strings.TrimSpace(value))); t {
+	case "", ContactPerson:
+		return ContactPerson, nil
+	case ContactGroup:
+		return t, nil
+	default:
+		return "", fmt.Errorf("invalid type value %q (expected person or group)", value)
+	}
+}
+
+// parseMediaPreference validates a value from the media column
+func parseMediaPreference(value string) (MediaPreference, error) {
+	switch pref := MediaPreference(strings.ToLower(strings.TrimSpace(value))); pref {
+	case MediaDefault, MediaImage, MediaText, MediaNone:
+		return pref, nil
+	default:
+		return "", fmt.Errorf("invalid media value %q (expected image, text or none)", value)
+	}
+}
+
+// CSVOptions controls how contact CSV columns are recognized
+type CSVOptions struct {
+	NameColumns  []string             // Header aliases for the name column, in priority order
+	PhoneColumns []string             // Header aliases for the phone column, in priority order
+	RequireName  bool                 // Fail if there is no name column instead of using the phone number
+	National     NationalNumberConfig // Conversion of national-format numbers to international
+	Schema       CSVSchema            // Expected columns, checked before parsing rows
+	Transforms   map[string][]string  // Named cleanups per column, applied as rows are read
+	OnBadRow     string               // "skip" to leave out rows that can't be parsed; otherwise they are an error
+	BadRowsPath  string               // Optional CSV the skipped rows are copied to, with row number and reason
+
+	VCardPhoneTypes []string // TEL types to prefer in vCard files, in priority order
+}
+
+// findColumn returns the index of the first header matching one of the aliases,
+// preferring aliases earlier in the list. It logs which column was chosen when
+// several headers match.
+func findColumn(headers []string, aliases []string, kind string) int {
+	var matches []int
+	for _, alias := range aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		for i, col := range headers {
+			if strings.ToLower(col) == alias {
+				matches = append(matches, i)
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return -1
+	}
+	if len(matches) > 1 {
+		Log("info", fmt.Sprintf("Multiple %s columns found, using '%s'", kind, headers[matches[0]]))
+	}
+	return matches[0]
+}
+
+// validateSchema checks the header against the declared schema: every
+// required column must be present, and columns that are neither required nor
+// optional are reported according to schema.Unexpected
+func validateSchema(headers []string, schema CSVSchema) error {
+	if len(schema.Required) == 0 && len(schema.Optional) == 0 {
+		return nil
+	}
+
+	present := make(map[string]bool, len(headers))
+	for _, col := range headers {
+		present[strings.ToLower(col)] = true
+	}
+	declared := make(map[string]bool)
+	for _, col := range append(append([]string{}, schema.Required...), schema.Optional...) {
+		declared[strings.ToLower(strings.TrimSpace(col))] = true
+	}
+
+	var missing []string
+	for _, col := range schema.Required {
+		if !present[strings.ToLower(strings.TrimSpace(col))] {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("CSV is missing required columns: %s (expected: %s; found: %s)",
+			strings.Join(missing, ", "), strings.Join(schema.Required, ", "), strings.Join(headers, ", "))
+	}
+
+	var unexpected []string
+	for _, col := range headers {
+		if !declared[strings.ToLower(col)] {
+			unexpected = append(unexpected, col)
+		}
+	}
+	if len(unexpected) > 0 {
+		msg := fmt.Sprintf("CSV has columns not in files.schema: %s (found: %s)",
+			strings.Join(unexpected, ", "), strings.Join(headers, ", "))
+		if schema.Unexpected == "error" {
+			return fmt.Errorf("%s", msg)
+		}
+		Log("warn", msg)
+	}
+
+	return nil
+}
+
+// ParseCSV reads every contact in the file into memory
+func ParseCSV(filePath string, opts CSVOptions) ([]Contact, error) {
+	reader, err := OpenContactReader(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var contacts []Contact
+	for {
+		contact, err := reader.Next()
+		if err == io.EOF {
+			return contacts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+}
+
+// ContactReader reads contacts from a CSV file one row at a time, so very
+// large lists can be processed without loading them into memory first
+type ContactReader struct {
+	file   *os.File
+	reader *csv.Reader
+	opts   CSVOptions
+
+	headers                     []string
+	nameIdx, phoneIdx, mediaIdx int
+	retriesIdx, typeIdx         int
+	keyIdx                      int // Column that decides whether a row is empty
+	transforms                  map[int][]string
+	samples                     int // Transform before/after pairs logged so far
+	row                         int // Current row number, the header is row 1
+
+	skipped     int         // Bad rows left out with on_bad_row: skip
+	badRowsFile *os.File    // Opened on the first skipped row when BadRowsPath is set
+	badRows     *csv.Writer // Writes to badRowsFile
+}
+
+// badRowError is a row that can't be turned into a contact
+type badRowError struct {
+	row    int
+	reason string
+}
+
+func (e *badRowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.row, e.reason)
+}
+
+// badRow returns the error for the current row
+func (r *ContactReader) badRow(format string, args ...interface{}) error {
+	return &badRowError{row: r.row, reason: fmt.Sprintf(format, args...)}
+}
+
+// OpenContactReader opens a contacts CSV and reads and checks its header
+func OpenContactReader(filePath string, opts CSVOptions) (*ContactReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	// Parse header
+	header, err := reader.Read()
+	if err == io.EOF {
+		file.Close()
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+	}
+
+	// Normalize headers and track all column indices
+	normalizedHeaders := make([]string, len(header))
+	for i, col := range header {
+		normalizedHeaders[i] = strings.TrimSpace(col)
+	}
+
+	if err := validateSchema(normalizedHeaders, opts.Schema); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r := &ContactReader{
+		file:       file,
+		reader:     reader,
+		opts:       opts,
+		headers:    normalizedHeaders,
+		nameIdx:    findColumn(normalizedHeaders, opts.NameColumns, "name"),
+		phoneIdx:   findColumn(normalizedHeaders, opts.PhoneColumns, "phone"),
+		mediaIdx:   findColumn(normalizedHeaders, []string{"media"}, "media"),
+		retriesIdx: findColumn(normalizedHeaders, []string{"max_retries"}, "max_retries"),
+		typeIdx:    findColumn(normalizedHeaders, []string{"type"}, "type"),
+		transforms: columnTransforms(normalizedHeaders, opts.Transforms),
+		row:        1,
+	}
+
+	if r.phoneIdx == -1 {
+		file.Close()
+		return nil, fmt.Errorf("CSV must contain a phone column (one of: %s)",
+			strings.Join(opts.PhoneColumns, ", "))
+	}
+	if r.nameIdx == -1 {
+		if opts.RequireName {
+			file.Close()
+			return nil, fmt.Errorf("CSV must contain a name column (one of: %s)",
+				strings.Join(opts.NameColumns, ", "))
+		}
+		Log("info", "No name column found, using phone numbers as contact names")
+	}
+
+	// Rows are considered empty based on the name column, or the phone
+	// column for phone-only lists
+	r.keyIdx = r.nameIdx
+	if r.keyIdx == -1 {
+		r.keyIdx = r.phoneIdx
+	}
+
+	return r, nil
+}
+
+// Next returns the next contact, skipping empty rows. It returns io.EOF
+// after the last contact.
+func (r *ContactReader) Next() (Contact, error) {
+	for {
+		row, err := r.reader.Read()
+		if err == io.EOF {
+			return Contact{}, io.EOF
+		}
+		r.row++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && r.opts.OnBadRow == "skip" {
+			if err := r.skip(row, parseErr.Err.Error()); err != nil {
+				return Contact{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return Contact{}, fmt.Errorf("failed to read CSV file: %w", err)
+		}
+
+		for j, names := range r.transforms {
+			if j >= len(row) {
+				continue
+			}
+			before := row[j]
+			row[j] = applyTransforms(before, names)
+			if row[j] != before && r.samples < maxTransformSamples {
+				Log("debug", fmt.Sprintf("Transform %s on %q, row %d: %q -> %q",
+					strings.Join(names, ","), r.headers[j], r.row, before, row[j]))
+				r.samples++
+			}
+		}
+
+		// Skip empty rows
+		if len(row) == 0 || (len(row) > r.keyIdx && strings.TrimSpace(row[r.keyIdx]) == "") {
+			continue
+		}
+
+		contact, err := r.parseRow(row)
+		var bad *badRowError
+		if errors.As(err, &bad) && r.opts.OnBadRow == "skip" {
+			if err := r.skip(row, bad.reason); err != nil {
+				return Contact{}, err
+			}
+			continue
+		}
+		return contact, err
+	}
+}
+
+// skip leaves a bad row out of the list, logging why and copying it to
+// BadRowsPath if set
+func (r *ContactReader) skip(row []string, reason string) error {
+	r.skipped++
+	Log("warn", fmt.Sprintf("Skipping row %d: %s", r.row, reason))
+	if r.opts.BadRowsPath == "" {
+		return nil
+	}
+
+	if r.badRows == nil {
+		file, err := os.Create(r.opts.BadRowsPath)
+		if err != nil {
+			return fmt.Errorf("failed to create bad rows CSV: %w", err)
+		}
+		r.badRowsFile = file
+		r.badRows = csv.NewWriter(file)
+		if err := r.badRows.Write(append([]string{"row", "reason"}, r.headers...)); err != nil {
+			return fmt.Errorf("failed to write bad rows CSV: %w", err)
+		}
+	}
+	if err := r.badRows.Write(append([]string{strconv.Itoa(r.row), reason}, row...)); err != nil {
+		return fmt.Errorf("failed to write bad rows CSV: %w", err)
+	}
+	return nil
+}
+
+// parseRow turns a non-empty row into a contact
+func (r *ContactReader) parseRow(row []string) (Contact, error) {
+	if len(row) <= r.nameIdx || len(row) <= r.phoneIdx {
+		return Contact{}, r.badRow("insufficient columns")
+	}
+
+	contact := Contact{
+		PhoneNumber: ToInternational(strings.TrimSpace(row[r.phoneIdx]), r.opts.National),
+		Type:        ContactPerson,
+		Fields:      make(map[string]string),
+	}
+
+	if r.typeIdx != -1 && len(row) > r.typeIdx {
+		contactType, err := parseContactType(row[r.typeIdx])
+		if err != nil {
+			return Contact{}, r.badRow("%v", err)
+		}
+		contact.Type = contactType
+	}
+
+	// Groups are found by name and have no number of their own
+	if contact.Type == ContactGroup {
+		if r.nameIdx == -1 || strings.TrimSpace(row[r.nameIdx]) == "" {
+			return Contact{}, r.badRow("group has no name to search for")
+		}
+	}
+
+	if r.nameIdx != -1 {
+		contact.Name = strings.TrimSpace(row[r.nameIdx])
+	} else {
+		contact.Name = contact.PhoneNumber
+	}
+
+	// Validate phone number format (basic validation)
+	if contact.PhoneNumber == "" && contact.Type != ContactGroup {
+		return Contact{}, r.badRow("empty phone number")
+	}
+
+	if r.mediaIdx != -1 && len(row) > r.mediaIdx {
+		media, err := parseMediaPreference(row[r.mediaIdx])
+		if err != nil {
+			return Contact{}, r.badRow("%v", err)
+		}
+		contact.Media = media
+	}
+
+	if r.retriesIdx != -1 && len(row) > r.retriesIdx {
+		contact.MaxRetries = parseMaxRetries(row[r.retriesIdx], r.row)
+	}
+
+	// Parse all additional fields (excluding name, phone, media, max_retries and type)
+	for j, value := range row {
+		if j != r.nameIdx && j != r.phoneIdx && j != r.mediaIdx && j != r.retriesIdx && j != r.typeIdx {
+			// Capitalize first letter of field name for template compatibility
+			fieldName := r.headers[j]
+			if len(fieldName) > 0 {
+				// Capitalize first letter: "value" -> "Value"
+				fieldName = strings.ToUpper(fieldName[:1]) + fieldName[1:]
+			}
+			contact.Fields[fieldName] = strings.TrimSpace(value)
+		}
+	}
+
+	return contact, nil
+}
+
+// Close closes the underlying file and the bad rows CSV, and reports how
+// many rows were skipped
+func (r *ContactReader) Close() error {
+	if r.skipped > 0 {
+		msg := fmt.Sprintf("Skipped %d bad rows in the contact list", r.skipped)
+		if r.badRows != nil {
+			msg += fmt.Sprintf(" (copied to %s)", r.opts.BadRowsPath)
+		}
+		Log("warn", msg)
+	}
+	if r.badRows != nil {
+		r.badRows.Flush()
+		if err := r.badRows.Error(); err != nil {
+			Log("warn", fmt.Sprintf("Failed to write bad rows CSV: %v", err))
+		}
+		r.badRowsFile.Close()
+		r.badRows = nil
+	}
+	return r.file.Close()
+}
+
+// LoadPhoneSet reads the contacts of another contact list into a set keyed
+// by excludeKey, so formatting differences don't matter when comparing
+func LoadPhoneSet(filePath string, opts CSVOptions) (map[string]bool, error) {
+	// Only the phone column matters here
+	opts.RequireName = false
+	opts.Schema = CSVSchema{}
+
+	contacts, err := LoadContacts(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	phones := make(map[string]bool, len(contacts))
+	for _, contact := range contacts {
+		if key := excludeKey(contact); key != "" {
+			phones[key] = true
+		}
+	}
+	return phones, nil
+}
+
+// excludeKey identifies a contact across lists: the clean phone number, or
+// for groups, which have no number, the name. Empty if there is neither.
+func excludeKey(contact Contact) string {
+	if contact.Type == ContactGroup {
+		if name := strings.TrimSpace(contact.Name); name != "" {
+			return "group:" + name
+		}
+		return ""
+	}
+	return CleanPhoneNumber(contact.PhoneNumber)
+}
+
+// IsExcluded reports whether the contact is in the exclude list set
+func IsExcluded(excluded map[string]bool, contact Contact) bool {
+	key := excludeKey(contact)
+	return key != "" && excluded[key]
+}
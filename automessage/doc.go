@@ -0,0 +1,5 @@
+// Package automessage is the browser-free core of the WhatsApp automation:
+// loading the config, contact lists and message templates, the completed
+// and unverified trackers, and PlanRun, which works out who a run would
+// message. The command in the repository root drives WhatsApp Web with it.
+package automessage
@@ -0,0 +1,218 @@
+package automessage
+
+import "regexp"
+
+// emojiShortcodePattern matches :shortcode: sequences as written on GitHub
+// and Slack
+var emojiShortcodePattern = regexp.MustCompile(`:[a-z0-9_+\-]+:`)
+
+// emojiShortcodes maps the commonly used shortcodes to their emoji
+var emojiShortcodes = map[string]string{
+	// Smileys
+	"smile":                        "😄",
+	"smiley":                       "😃",
+	"grinning":                     "😀",
+	"grin":                         "😁",
+	"laughing":                     "😆",
+	"sweat_smile":                  "😅",
+	"joy":                          "😂",
+	"rofl":                         "🤣",
+	"slightly_smiling_face":        "🙂",
+	"upside_down_face":             "🙃",
+	"wink":                         "😉",
+	"blush":                        "😊",
+	"innocent":                     "😇",
+	"heart_eyes":                   "😍",
+	"star_struck":                  "🤩",
+	"kissing_heart":                "😘",
+	"yum":                          "😋",
+	"stuck_out_tongue_winking_eye": "😜",
+	"hugs":                         "🤗",
+	"thinking":                     "🤔",
+	"shushing_face":                "🤫",
+	"neutral_face":                 "😐",
+	"expressionless":               "😑",
+	"smirk":                        "😏",
+	"roll_eyes":                    "🙄",
+	"relieved":                     "😌",
+	"pensive":                      "😔",
+	"sleepy":                       "😪",
+	"sleeping":                     "😴",
+	"sunglasses":                   "😎",
+	"nerd_face":                    "🤓",
+	"partying_face":                "🥳",
+	"confused":                     "😕",
+	"worried":                      "😟",
+	"slightly_frowning_face":       "🙁",
+	"open_mouth":                   "😮",
+	"astonished":                   "😲",
+	"flushed":                      "😳",
+	"pleading_face":                "🥺",
+	"cry":                          "😢",
+	"sob":                          "😭",
+	"scream":                       "😱",
+	"angry":                        "😠",
+	"rage":                         "😡",
+	"sweat":                        "😓",
+	"mask":                         "😷",
+
+	// Gestures and people
+	"wave":            "👋",
+	"ok_hand":         "👌",
+	"+1":              "👍",
+	"thumbsup":        "👍",
+	"-1":              "👎",
+	"thumbsdown":      "👎",
+	"clap":            "👏",
+	"raised_hands":    "🙌",
+	"pray":            "🙏",
+	"handshake":       "🤝",
+	"muscle":          "💪",
+	"point_right":     "👉",
+	"point_left":      "👈",
+	"point_up":        "☝️",
+	"point_down":      "👇",
+	"v":               "✌️",
+	"crossed_fingers": "🤞",
+	"fist":            "✊",
+	"raised_hand":     "✋",
+	"writing_hand":    "✍️",
+	"eyes":            "👀",
+	"family":          "👪",
+	"man":             "👨",
+	"woman":           "👩",
+	"baby":            "👶",
+
+	// Hearts and symbols
+	"heart":            "❤️",
+	"orange_heart":     "🧡",
+	"yellow_heart":     "💛",
+	"green_heart":      "💚",
+	"blue_heart":       "💙",
+	"purple_heart":     "💜",
+	"black_heart":      "🖤",
+	"white_heart":      "🤍",
+	"broken_heart":     "💔",
+	"two_hearts":       "💕",
+	"sparkling_heart":  "💖",
+	"100":              "💯",
+	"fire":             "🔥",
+	"sparkles":         "✨",
+	"star":             "⭐",
+	"star2":            "🌟",
+	"boom":             "💥",
+	"zap":              "⚡",
+	"tada":             "🎉",
+	"confetti_ball":    "🎊",
+	"balloon":          "🎈",
+	"gift":             "🎁",
+	"trophy":           "🏆",
+	"medal_sports":     "🏅",
+	"white_check_mark": "✅",
+	"heavy_check_mark": "✔️",
+	"x":                "❌",
+	"warning":          "⚠️",
+	"exclamation":      "❗",
+	"question":         "❓",
+	"bangbang":         "‼️",
+	"arrow_right":      "➡️",
+	"arrow_left":       "⬅️",
+	"arrow_up":         "⬆️",
+	"arrow_down":       "⬇️",
+	"new":              "🆕",
+	"free":             "🆓",
+	"sos":              "🆘",
+	"star_of_david":    "✡️",
+	"menorah":          "🕎",
+	"dove":             "🕊️",
+	"pushpin":          "📌",
+	"round_pushpin":    "📍",
+	"link":             "🔗",
+	"bell":             "🔔",
+	"mega":             "📣",
+	"loudspeaker":      "📢",
+	"speech_balloon":   "💬",
+	"bulb":             "💡",
+	"moneybag":         "💰",
+	"dollar":           "💵",
+	"credit_card":      "💳",
+	"lock":             "🔒",
+	"key":              "🔑",
+
+	// Objects, places and time
+	"calendar":       "📆",
+	"date":           "📅",
+	"clock":          "🕐",
+	"alarm_clock":    "⏰",
+	"hourglass":      "⌛",
+	"phone":          "☎️",
+	"iphone":         "📱",
+	"email":          "📧",
+	"envelope":       "✉️",
+	"memo":           "📝",
+	"book":           "📖",
+	"books":          "📚",
+	"camera":         "📷",
+	"tv":             "📺",
+	"computer":       "💻",
+	"shopping_cart":  "🛒",
+	"house":          "🏠",
+	"office":         "🏢",
+	"school":         "🏫",
+	"synagogue":      "🕍",
+	"car":            "🚗",
+	"bus":            "🚌",
+	"airplane":       "✈️",
+	"rocket":         "🚀",
+	"world_map":      "🗺️",
+	"earth_americas": "🌎",
+	"sunny":          "☀️",
+	"cloud":          "☁️",
+	"umbrella":       "☔",
+	"snowflake":      "❄️",
+	"rainbow":        "🌈",
+	"crescent_moon":  "🌙",
+	"candle":         "🕯️",
+
+	// Food and drink
+	"coffee":           "☕",
+	"tea":              "🍵",
+	"wine_glass":       "🍷",
+	"beer":             "🍺",
+	"champagne":        "🍾",
+	"clinking_glasses": "🥂",
+	"cake":             "🍰",
+	"birthday":         "🎂",
+	"pizza":            "🍕",
+	"hamburger":        "🍔",
+	"bread":            "🍞",
+	"apple":            "🍎",
+	"grapes":           "🍇",
+	"fork_and_knife":   "🍴",
+
+	// Nature and animals
+	"seedling":         "🌱",
+	"herb":             "🌿",
+	"four_leaf_clover": "🍀",
+	"rose":             "🌹",
+	"sunflower":        "🌻",
+	"tulip":            "🌷",
+	"bouquet":          "💐",
+	"palm_tree":        "🌴",
+	"dog":              "🐶",
+	"cat":              "🐱",
+	"lion":             "🦁",
+	"butterfly":        "🦋",
+	"camel":            "🐫",
+}
+
+// ExpandEmoji replaces known :shortcode: sequences with their emoji and
+// leaves unknown ones untouched
+func ExpandEmoji(text string) string {
+	return emojiShortcodePattern.ReplaceAllStringFunc(text, func(match string) string {
+		if emoji, ok := emojiShortcodes[match[1:len(match)-1]]; ok {
+			return emoji
+		}
+		return match
+	})
+}
@@ -0,0 +1,30 @@
+package automessage
+
+import "testing"
+
+func TestExpandEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"known shortcode", "I :heart: it", "I ❤️ it"},
+		{"shortcode with symbol", "Great job :+1:", "Great job 👍"},
+		{"shortcode with underscore", "See you at the :palm_tree:", "See you at the 🌴"},
+		{"several shortcodes", ":dog: and :cat:", "🐶 and 🐱"},
+		{"unknown shortcode kept", "Hello :not_an_emoji:", "Hello :not_an_emoji:"},
+		{"known next to unknown", ":dog::unicorn_cat:", "🐶:unicorn_cat:"},
+		{"uppercase is not a shortcode", ":HEART:", ":HEART:"},
+		{"time is not a shortcode", "Meet at 10:30:00", "Meet at 10:30:00"},
+		{"lone colons", "Note: details: below", "Note: details: below"},
+		{"no shortcodes", "Plain text", "Plain text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandEmoji(tt.input); got != tt.want {
+				t.Errorf("ExpandEmoji(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
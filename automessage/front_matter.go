@@ -0,0 +1,168 @@
+package automessage
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateFrontMatter is the settings a template file can carry in YAML
+// front-matter between "---" lines at its top. Unset keys leave the config
+// alone.
+type TemplateFrontMatter struct {
+	// Overlaid on the config, from the message template (files.template_path) only
+	ImagePath         *string `yaml:"image_path"`
+	ImagePathTemplate *string `yaml:"image_path_template"`
+	OpenerPath        *string `yaml:"opener_path"`
+	CaptionPath       *string `yaml:"caption_path"`
+	ImageThenText     *bool   `yaml:"image_then_text"`
+	MessageSeparator  *string `yaml:"message_separator"`
+	ExpandEmoji       *bool   `yaml:"expand_emoji"`
+	TrimBlankLines    *bool   `yaml:"trim_blank_lines"`
+	AllowEmptyCaption *bool   `yaml:"allow_empty_caption"`
+
+	// How this file itself is parsed, in any template file
+	Delimiters   []string `yaml:"delimiters"`    // Action delimiters instead of {{ and }}, e.g. ["[[", "]]"]
+	StrictFields bool     `yaml:"strict_fields"` // Fail on fields the contact doesn't have instead of rendering "<no value>"
+}
+
+// splitFrontMatter separates YAML front-matter from the template body. A
+// file counts as having front-matter only when it starts with a "---" line,
+// a later "---" line closes it and what's in between is a YAML mapping, so
+// templates that merely start with a message separator are unaffected.
+func splitFrontMatter(content string) (front map[string]interface{}, body string) {
+	rest, ok := cutLine(content, "---")
+	if !ok {
+		return nil, content
+	}
+
+	var header []string
+	for rest != "" {
+		line, next, _ := strings.Cut(rest, "\n")
+		if strings.TrimRight(line, "\r") == "---" {
+			if err := yaml.Unmarshal([]byte(strings.Join(header, "\n")), &front); err != nil || front == nil {
+				return nil, content
+			}
+			return front, next
+		}
+		header = append(header, line)
+		rest = next
+	}
+	return nil, content
+}
+
+// cutLine returns the text after the first line if that line is want
+func cutLine(content, want string) (string, bool) {
+	line, rest, found := strings.Cut(content, "\n")
+	if !found || strings.TrimRight(strings.TrimPrefix(line, "\ufeff"), "\r") != want {
+		return "", false
+	}
+	return rest, true
+}
+
+// parseFrontMatter decodes front-matter into the known settings and
+// returns the keys it doesn't know
+func parseFrontMatter(filePath string, front map[string]interface{}) (settings TemplateFrontMatter, unknown []string, err error) {
+	if len(front) == 0 {
+		return settings, nil, nil
+	}
+
+	known := make(map[string]bool)
+	settingsType := reflect.TypeOf(settings)
+	for i := 0; i < settingsType.NumField(); i++ {
+		known[settingsType.Field(i).Tag.Get("yaml")] = true
+	}
+	for key := range front {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	// Re-encode so yaml does the type checking of the known keys
+	data, err := yaml.Marshal(front)
+	if err != nil {
+		return settings, nil, fmt.Errorf("invalid front-matter in %s: %w", filePath, err)
+	}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return settings, nil, fmt.Errorf("invalid front-matter in %s: %w", filePath, err)
+	}
+	if settings.Delimiters != nil && (len(settings.Delimiters) != 2 || settings.Delimiters[0] == "" || settings.Delimiters[1] == "") {
+		return settings, nil, fmt.Errorf("invalid front-matter in %s: delimiters must be a left and a right delimiter, e.g. [\"[[\", \"]]\"]", filePath)
+	}
+	return settings, unknown, nil
+}
+
+// readTemplateFile reads a template file and splits off its front-matter,
+// warning about unknown keys
+func readTemplateFile(filePath string) (body string, settings TemplateFrontMatter, err error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", settings, err
+	}
+	front, body := splitFrontMatter(string(content))
+	settings, unknown, err := parseFrontMatter(filePath, front)
+	if err != nil {
+		return "", settings, err
+	}
+	if len(unknown) > 0 {
+		Log("warn", fmt.Sprintf("Ignoring unknown front-matter keys in %s: %s", filePath, strings.Join(unknown, ", ")))
+	}
+	return body, settings, nil
+}
+
+// parseTemplateBody parses a template body into t with the file's own
+// delimiters and strict mode
+func parseTemplateBody(t *template.Template, body string, settings TemplateFrontMatter) (*template.Template, error) {
+	if settings.Delimiters != nil {
+		t = t.Delims(settings.Delimiters[0], settings.Delimiters[1])
+	}
+	if settings.StrictFields {
+		t = t.Option("missingkey=error")
+	}
+	return t.Parse(body)
+}
+
+// applyTemplateFrontMatter overlays the front-matter of the message template
+// on the config, before defaults and validation. A template that can't be
+// read is left for LoadTemplate to report.
+func applyTemplateFrontMatter(config *Config) error {
+	if config.Files.TemplatePath == "" || config.Files.MessageColumn != "" {
+		return nil
+	}
+	content, err := os.ReadFile(config.Files.TemplatePath)
+	if err != nil {
+		return nil
+	}
+	front, _ := splitFrontMatter(string(content))
+	settings, _, err := parseFrontMatter(config.Files.TemplatePath, front)
+	if err != nil {
+		return err
+	}
+
+	setString := func(target *string, value *string) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	setBool := func(target *bool, value *bool) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	setString(&config.Files.ImagePath, settings.ImagePath)
+	setString(&config.Files.ImagePathTemplate, settings.ImagePathTemplate)
+	setString(&config.Template.OpenerPath, settings.OpenerPath)
+	setString(&config.Template.CaptionPath, settings.CaptionPath)
+	setBool(&config.Template.ImageThenText, settings.ImageThenText)
+	setString(&config.Template.MessageSeparator, settings.MessageSeparator)
+	setBool(&config.Template.ExpandEmoji, settings.ExpandEmoji)
+	setBool(&config.Template.TrimBlankLines, settings.TrimBlankLines)
+	setBool(&config.Template.AllowEmptyCaption, settings.AllowEmptyCaption)
+	return nil
+}
@@ -1,23 +1,57 @@
-package main
+package automessage
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	logLevel   string
 	logFile    *os.File
 	infoLogger *log.Logger
 	warnLogger *log.Logger
 	errLogger  *log.Logger
+	consoleOut io.Writer = os.Stdout // Console destination for info/warn messages
+
+	// Guards the loggers and console writes above, so lines logged from
+	// different goroutines never interleave
+	logMu sync.Mutex
+
+	// Lowest priority that gets logged, set by InitLogger
+	minLogPriority atomic.Int32
 )
 
+// levelPriority orders the log levels; unknown levels count as debug
+var levelPriority = map[string]int32{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+}
+
+// DebugEnabled reports whether debug messages are being logged
+func DebugEnabled() bool {
+	return minLogPriority.Load() == levelPriority["debug"]
+}
+
+// SetConsoleOutput redirects info and warn console messages, e.g. to stderr
+// when stdout is reserved for machine-readable output
+func SetConsoleOutput(w io.Writer) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	consoleOut = w
+}
+
 func InitLogger(config *Config) error {
-	logLevel = strings.ToLower(config.Logging.Level)
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	minLogPriority.Store(levelPriority[strings.ToLower(config.Logging.Level)])
 
 	// Open log file if specified
 	if config.Logging.OutputFile != "" {
@@ -36,8 +70,8 @@ func InitLogger(config *Config) error {
 		warnLogger = log.New(logFile, "[WARN] ", flags)
 		errLogger = log.New(logFile, "[ERROR] ", flags)
 	} else {
-		infoLogger = log.New(os.Stdout, "[INFO] ", flags)
-		warnLogger = log.New(os.Stdout, "[WARN] ", flags)
+		infoLogger = log.New(consoleOut, "[INFO] ", flags)
+		warnLogger = log.New(consoleOut, "[WARN] ", flags)
 		errLogger = log.New(os.Stderr, "[ERROR] ", flags)
 	}
 
@@ -45,6 +79,8 @@ func InitLogger(config *Config) error {
 }
 
 func CloseLogger() {
+	logMu.Lock()
+	defer logMu.Unlock()
 	if logFile != nil {
 		logFile.Close()
 	}
@@ -54,34 +90,27 @@ func Log(level, message string) {
 	level = strings.ToLower(level)
 
 	// Determine if we should log based on configured level
-	levelPriority := map[string]int{
-		"debug": 0,
-		"info":  1,
-		"warn":  2,
-		"error": 3,
-	}
-
-	configuredPriority := levelPriority[logLevel]
-	messagePriority := levelPriority[level]
-
-	if messagePriority < configuredPriority {
+	if levelPriority[level] < minLogPriority.Load() {
 		return // Skip logging
 	}
 
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	formattedMsg := fmt.Sprintf("[%s] %s", timestamp, message)
 
+	logMu.Lock()
+	defer logMu.Unlock()
+
 	switch level {
 	case "debug", "info":
 		if infoLogger != nil {
 			infoLogger.Println(message)
 		}
-		fmt.Println(formattedMsg)
+		fmt.Fprintln(consoleOut, formattedMsg)
 	case "warn":
 		if warnLogger != nil {
 			warnLogger.Println(message)
 		}
-		fmt.Println(formattedMsg)
+		fmt.Fprintln(consoleOut, formattedMsg)
 	case "error":
 		if errLogger != nil {
 			errLogger.Println(message)
@@ -0,0 +1,63 @@
+package automessage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestLogConcurrent logs from many goroutines at once; run with -race to
+// check the shared loggers, and check no two lines were interleaved
+func TestLogConcurrent(t *testing.T) {
+	var console bytes.Buffer
+	SetConsoleOutput(&console)
+	defer SetConsoleOutput(os.Stdout)
+
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	cfg := &Config{}
+	cfg.Logging.Level = "debug"
+	cfg.Logging.OutputFile = logPath
+	if err := InitLogger(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		CloseLogger()
+		logFile, infoLogger, warnLogger, errLogger = nil, nil, nil, nil
+		minLogPriority.Store(0)
+	}()
+
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				level := []string{"debug", "info", "warn"}[i%3]
+				Log(level, fmt.Sprintf("worker %d message %d", w, i))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, out := range map[string]string{"log file": string(data), "console": console.String()} {
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(lines) != workers*perWorker {
+			t.Errorf("%s: got %d lines, want %d", name, len(lines), workers*perWorker)
+		}
+		for _, line := range lines {
+			if strings.Count(line, "worker ") != 1 || !strings.Contains(line, " message ") {
+				t.Errorf("%s: garbled line %q", name, line)
+				break
+			}
+		}
+	}
+}
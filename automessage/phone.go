@@ -0,0 +1,106 @@
+package automessage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// E.164 allows at most 15 digits including the country code; anything much
+// shorter than 8 can't be a full international number
+const (
+	minPhoneDigits = 8
+	maxPhoneDigits = 15
+)
+
+// ValidatePhoneNumber checks that a phone number looks like a full
+// international number that WhatsApp can open a chat for
+func ValidatePhoneNumber(phoneNumber string) error {
+	trimmed := strings.TrimSpace(phoneNumber)
+	if trimmed == "" {
+		return fmt.Errorf("phone number is empty")
+	}
+
+	digits := strings.TrimPrefix(NormalizePhoneNumber(trimmed), "+")
+
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("phone number %q contains invalid character %q", phoneNumber, r)
+		}
+	}
+
+	if strings.HasPrefix(digits, "0") {
+		return fmt.Errorf("phone number %q starts with 0 - use international format with country code", phoneNumber)
+	}
+
+	if len(digits) < minPhoneDigits {
+		return fmt.Errorf("phone number %q is too short (%d digits, need at least %d including country code)",
+			phoneNumber, len(digits), minPhoneDigits)
+	}
+	if len(digits) > maxPhoneDigits {
+		return fmt.Errorf("phone number %q is too long (%d digits, max %d)",
+			phoneNumber, len(digits), maxPhoneDigits)
+	}
+
+	return nil
+}
+
+// phoneFormatting strips the formatting characters people commonly use in numbers
+var phoneFormatting = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+
+// digitZeros lists the zero of each non-ASCII decimal digit block commonly
+// found in exported contact lists (Arabic-Indic, Persian, Devanagari,
+// Bengali and fullwidth digits)
+var digitZeros = []rune{'\u0660', '\u06F0', '\u0966', '\u09E6', '\uFF10'}
+
+// ASCIIDigits replaces non-ASCII decimal digits with their ASCII equivalents
+func ASCIIDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		for _, zero := range digitZeros {
+			if r >= zero && r <= zero+9 {
+				return '0' + (r - zero)
+			}
+		}
+		return r
+	}, s)
+}
+
+// NormalizePhoneNumber removes formatting characters and converts non-ASCII
+// digits, keeping a leading +. The "00" international dialing prefix is
+// treated as +.
+func NormalizePhoneNumber(phoneNumber string) string {
+	trimmed := phoneFormatting.Replace(ASCIIDigits(strings.TrimSpace(phoneNumber)))
+	if strings.HasPrefix(trimmed, "+") {
+		return trimmed
+	}
+	if strings.HasPrefix(trimmed, "00") {
+		return "+" + trimmed[2:]
+	}
+	return trimmed
+}
+
+// CleanPhoneNumber returns the number as WhatsApp expects it in a chat URL:
+// country code and digits only, without + or formatting
+func CleanPhoneNumber(phoneNumber string) string {
+	return strings.TrimPrefix(NormalizePhoneNumber(phoneNumber), "+")
+}
+
+// ToInternational rewrites a number in national format (no + and starting
+// with the trunk 0) into international format using the configured country
+// code, e.g. "054-1234567" -> "+972541234567" for Israel. Numbers that are
+// already international, or any number when no country code is configured,
+// are returned unchanged.
+func ToInternational(phoneNumber string, rule NationalNumberConfig) string {
+	if rule.CountryCode == "" {
+		return phoneNumber
+	}
+
+	normalized := NormalizePhoneNumber(phoneNumber)
+	if !strings.HasPrefix(normalized, "0") {
+		return phoneNumber
+	}
+
+	if rule.StripLeadingZero {
+		normalized = normalized[1:]
+	}
+	return "+" + rule.CountryCode + normalized
+}
@@ -0,0 +1,73 @@
+package automessage
+
+import "testing"
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"already normalized", "+15102168856", "+15102168856"},
+		{"dashes", "+1-510-216-8856", "+15102168856"},
+		{"parentheses", "+1 (510) 216-8856", "+15102168856"},
+		{"spaces", " +972 54 123 4567 ", "+972541234567"},
+		{"dots", "510.216.8856", "5102168856"},
+		{"leading 00", "00972541234567", "+972541234567"},
+		{"leading 00 with formatting", "00 44 (20) 7946-0958", "+442079460958"},
+		{"arabic-indic digits", "+٩٧٢٥٤١٢٣٤٥٦٧", "+972541234567"},
+		{"persian digits", "۰۰۹۷۲۵۴۱۲۳۴۵۶۷", "+972541234567"},
+		{"devanagari digits", "+९१ ९८७६५ ४३२१०", "+919876543210"},
+		{"fullwidth digits", "+１ ５１０ ２１６ ８８５６", "+15102168856"},
+		{"national number", "054-1234567", "0541234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizePhoneNumber(tt.input); got != tt.want {
+				t.Errorf("NormalizePhoneNumber(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanPhoneNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"+1 (510) 216-8856", "15102168856"},
+		{"0015102168856", "15102168856"},
+		{"15102168856", "15102168856"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := CleanPhoneNumber(tt.input); got != tt.want {
+			t.Errorf("CleanPhoneNumber(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePhoneNumber(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"+1 (510) 216-8856", false},
+		{"00972-54-123-4567", false},
+		{"+٩٧٢٥٤١٢٣٤٥٦٧", false},
+		{"", true},
+		{"054-1234567", true},       // National format
+		{"+1234", true},             // Too short
+		{"+1234567890123456", true}, // Too long
+		{"+1510abc8856", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePhoneNumber(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePhoneNumber(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package automessage
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CompilePatterns compiles a list of regexes from the config, naming the
+// offending key and entry on error
+func CompilePatterns(key string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s[%d] %q: %w", key, i, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// MatchingPattern returns the first pattern matching the error's text, or
+// nil if none does
+func MatchingPattern(patterns []*regexp.Regexp, err error) *regexp.Regexp {
+	text := err.Error()
+	for _, re := range patterns {
+		if re.MatchString(text) {
+			return re
+		}
+	}
+	return nil
+}
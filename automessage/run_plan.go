@@ -0,0 +1,228 @@
+package automessage
+
+import (
+	"fmt"
+	"os"
+)
+
+// RunTemplates holds every template a run renders for a contact
+type RunTemplates struct {
+	Message   *MessageTemplate
+	Opener    *MessageTemplate // Optional, sent first in brand-new chats
+	Caption   *MessageTemplate // Optional, image caption for image_then_text
+	ImagePath *MessageTemplate // Optional, per-contact image path
+	Carousel  []carouselImage  // Optional, files.images sent before the message
+}
+
+// RunPlan is who a run would message: the loaded contacts minus those
+// already completed, unverified or excluded
+type RunPlan struct {
+	Loaded     int
+	Completed  int // Already sent in an earlier run
+	Unverified int // Possibly sent in an earlier run, left out unless retry.resend_unverified
+	Excluded   int // Also in files.exclude_csv
+	Changed    int // Of Remaining, sent before but the row or template changed since
+	Remaining  []Contact
+}
+
+// CSVOptionsFor returns the contact list parsing options from the config
+func CSVOptionsFor(config *Config) CSVOptions {
+	return CSVOptions{
+		NameColumns:  config.Files.NameColumns,
+		PhoneColumns: config.Files.PhoneColumns,
+		RequireName:  config.Files.RequireName,
+		National:     config.Files.NationalToInternational,
+		Schema:       config.Files.Schema,
+		Transforms:   config.Files.Transforms,
+		OnBadRow:     config.Files.OnBadRow,
+		BadRowsPath:  config.Files.BadRowsCSV,
+
+		VCardPhoneTypes: config.Files.VCardPhoneTypes,
+	}
+}
+
+// LoadRunTemplates loads the message template and the optional opener,
+// caption and image path templates, with the configured post-render passes
+// and campaign-level variables applied
+func LoadRunTemplates(config *Config) (*RunTemplates, error) {
+	var templates RunTemplates
+	var err error
+
+	templates.Message, err = LoadMessageTemplate(config.Files, config.Template.Variants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+
+	// Load the optional opener for brand-new chats
+	if config.Template.OpenerPath != "" {
+		Log("info", fmt.Sprintf("Loading opener template from %s", config.Template.OpenerPath))
+		templates.Opener, err = LoadTemplate(config.Template.OpenerPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load opener template: %w", err)
+		}
+	}
+
+	// Load the short caption for image_then_text sends
+	if config.Template.ImageThenText {
+		Log("info", fmt.Sprintf("Loading caption template from %s", config.Template.CaptionPath))
+		templates.Caption, err = LoadTemplate(config.Template.CaptionPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load caption template: %w", err)
+		}
+	}
+
+	// Parse the optional per-contact image path template
+	if config.Files.ImagePathTemplate != "" {
+		templates.ImagePath, err = NewMessageTemplate(config.Files.ImagePathTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image_path_template: %w", err)
+		}
+	}
+
+	// Parse the captions of the image carousel
+	if len(config.Files.Images) > 0 {
+		templates.Carousel, err = loadCarousel(config.Files.Images)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	templates.Message.SetSeparator(config.Template.MessageSeparator)
+
+	// Post-render passes over everything that is sent as text
+	texts := append([]*MessageTemplate{templates.Message, templates.Opener, templates.Caption}, carouselTemplates(templates.Carousel)...)
+	for _, t := range texts {
+		if t != nil {
+			t.SetExpandEmoji(config.Template.ExpandEmoji)
+			t.SetTrimBlankLines(config.Template.TrimBlankLines)
+		}
+	}
+
+	// Load campaign-level template variables
+	if config.Files.TemplateVars != "" {
+		Log("info", fmt.Sprintf("Loading template variables from %s", config.Files.TemplateVars))
+		vars, err := LoadTemplateVars(config.Files.TemplateVars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template variables: %w", err)
+		}
+		override := config.Files.TemplateVarsPrecedence == "global"
+		for _, t := range append(texts, templates.ImagePath) {
+			if t != nil {
+				t.SetGlobals(vars, override)
+			}
+		}
+		Log("info", fmt.Sprintf("Loaded %d template variables", len(vars)))
+	}
+
+	return &templates, nil
+}
+
+// LoadExcludedPhones reads files.exclude_csv into a set of clean phone
+// numbers; nil when no exclude list is configured
+func LoadExcludedPhones(config *Config) (map[string]bool, error) {
+	if config.Files.ExcludeCSV == "" {
+		return nil, nil
+	}
+
+	Log("info", fmt.Sprintf("Loading excluded contacts from %s", config.Files.ExcludeCSV))
+	phones, err := LoadPhoneSet(config.Files.ExcludeCSV, CSVOptions{
+		PhoneColumns:    config.Files.PhoneColumns,
+		National:        config.Files.NationalToInternational,
+		VCardPhoneTypes: config.Files.VCardPhoneTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	Log("info", fmt.Sprintf("Loaded %d excluded contacts", len(phones)))
+	return phones, nil
+}
+
+// FilterContacts splits contacts the way a run skips them, in the same order
+// of precedence: excluded, then completed, then unverified
+func FilterContacts(contacts []Contact, tracker, unverifiedTracker Tracker, excludedPhones map[string]bool, resendUnverified bool) RunPlan {
+	plan := RunPlan{Loaded: len(contacts), Remaining: make([]Contact, 0, len(contacts))}
+	for _, contact := range contacts {
+		switch {
+		case IsExcluded(excludedPhones, contact):
+			plan.Excluded++
+		case tracker.IsCompleted(contact):
+			plan.Completed++
+		case !resendUnverified && unverifiedTracker.IsCompleted(contact):
+			plan.Unverified++
+		default:
+			if tracker.SentBefore(contact) {
+				plan.Changed++
+			}
+			plan.Remaining = append(plan.Remaining, contact)
+		}
+	}
+	return plan
+}
+
+// PlanRun works out who a run with this config would message, without a
+// browser or sending anything: it loads the contact list, the completed and
+// unverified trackers and the exclude list, and returns the counts and the
+// contacts still to be messaged. Nothing is written.
+func PlanRun(config *Config) (RunPlan, error) {
+	csvOpts := CSVOptionsFor(config)
+	csvOpts.BadRowsPath = "" // Bad rows are still skipped, just not written out
+	contacts, err := LoadContacts(config.Files.CSVPath, csvOpts)
+	if err != nil {
+		return RunPlan{}, fmt.Errorf("failed to load contacts: %w", err)
+	}
+
+	// The trackers key sends on the rendered template, so it must be loaded
+	// exactly as a run would
+	templates, err := LoadRunTemplates(config)
+	if err != nil {
+		return RunPlan{}, err
+	}
+
+	tracker, err := NewCompletedTracker(config.Files.CompletedCSVPath, templates.Message, config.Tracker.KeyStrategy)
+	if err != nil {
+		return RunPlan{}, fmt.Errorf("failed to load completed tracker: %w", err)
+	}
+	defer tracker.Close()
+
+	unverifiedTracker, err := NewCompletedTracker(config.Files.UnverifiedCSVPath, templates.Message, config.Tracker.KeyStrategy)
+	if err != nil {
+		return RunPlan{}, fmt.Errorf("failed to load unverified tracker: %w", err)
+	}
+	defer unverifiedTracker.Close()
+
+	excludedPhones, err := LoadExcludedPhones(config)
+	if err != nil {
+		return RunPlan{}, fmt.Errorf("failed to load exclude CSV: %w", err)
+	}
+
+	return FilterContacts(contacts, tracker, unverifiedTracker, excludedPhones, config.Retry.ResendUnverified), nil
+}
+
+// SuspiciousCompleted reports why the completed file looks like it belongs
+// to another list, or "" if it doesn't: more than
+// tracker.guard_completed_percent of the contacts count as completed even
+// though the contact list changed after the last send was recorded, as when
+// a new list is run against an old campaign's completed file
+func SuspiciousCompleted(config *Config, plan RunPlan) string {
+	guard := config.Tracker.GuardCompletedPercent
+	if guard < 0 || plan.Loaded == 0 || plan.Completed == 0 {
+		return ""
+	}
+	percent := float64(plan.Completed) * 100 / float64(plan.Loaded)
+	if percent <= guard {
+		return ""
+	}
+
+	listInfo, err := os.Stat(config.Files.CSVPath)
+	if err != nil {
+		return ""
+	}
+	completedInfo, err := os.Stat(config.Files.CompletedCSVPath)
+	if err != nil || !listInfo.ModTime().After(completedInfo.ModTime()) {
+		return "" // Sends were recorded against this version of the list
+	}
+
+	return fmt.Sprintf("%.0f%% of %s (%d of %d contacts) is already marked completed in %s, which was last written %s, before the list was last changed (%s)",
+		percent, config.Files.CSVPath, plan.Completed, plan.Loaded, config.Files.CompletedCSVPath,
+		completedInfo.ModTime().Format("2006-01-02 15:04"), listInfo.ModTime().Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,59 @@
+package automessage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	csvPath := write("contacts.csv", "name,phone_number\nAda,+15102168856\nBen,+15102168857\nCy,+15102168858\n")
+	write("exclude.csv", "phone_number\n+1 (510) 216-8858\n")
+	write("template.txt", "Hi {{.Name}}")
+	configPath := write("config.yaml", `files:
+  csv_path: "`+csvPath+`"
+  template_path: "`+filepath.Join(dir, "template.txt")+`"
+  completed_csv_path: "`+filepath.Join(dir, "completed.csv")+`"
+  unverified_csv_path: "`+filepath.Join(dir, "unverified.csv")+`"
+  exclude_csv: "`+filepath.Join(dir, "exclude.csv")+`"
+`)
+
+	config, err := LoadConfig(configPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark Ada completed the way a run would
+	templates, err := LoadRunTemplates(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := NewCompletedTracker(config.Files.CompletedCSVPath, templates.Message, config.Tracker.KeyStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.MarkCompleted(Contact{Name: "Ada", PhoneNumber: "+15102168856", Fields: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Close()
+
+	plan, err := PlanRun(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Loaded != 3 || plan.Completed != 1 || plan.Excluded != 1 || plan.Unverified != 0 {
+		t.Errorf("got loaded %d, completed %d, excluded %d, unverified %d; want 3, 1, 1, 0",
+			plan.Loaded, plan.Completed, plan.Excluded, plan.Unverified)
+	}
+	if len(plan.Remaining) != 1 || plan.Remaining[0].Name != "Ben" {
+		t.Errorf("got remaining %+v, want only Ben", plan.Remaining)
+	}
+}
@@ -0,0 +1,340 @@
+package automessage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+type MessageTemplate struct {
+	tmpl    *template.Template
+	Content string // Raw template content for hashing
+
+	globals         map[string]interface{} // Campaign-level variables shared by all contacts
+	globalsOverride bool                   // Globals win over contact fields on key conflicts
+	expandEmoji     bool                   // Replace :shortcode: sequences with emoji after rendering
+	trimBlankLines  bool                   // Collapse blank-line runs and trailing spaces after rendering
+
+	column string // Take each contact's message verbatim from this CSV column instead
+
+	separator string // A line with only this text splits the message into separate sends
+
+	variants []messageVariant // A/B templates picked per contact (template.variants)
+}
+
+// LoadTemplateVars reads a YAML or JSON file of global template variables
+func LoadTemplateVars(filePath string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template vars file: %w", err)
+	}
+
+	// JSON is valid YAML, so one parser handles both formats
+	vars := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &vars); err != nil {
+		return nil, fmt.Errorf("failed to parse template vars file: %w", err)
+	}
+
+	return vars, nil
+}
+
+// SetGlobals sets campaign-level variables available to every contact.
+// If override is true they take precedence over contact fields with the same name.
+func (mt *MessageTemplate) SetGlobals(globals map[string]interface{}, override bool) {
+	mt.globals = globals
+	mt.globalsOverride = override
+	for _, variant := range mt.variants {
+		variant.template.SetGlobals(globals, override)
+	}
+}
+
+// SetExpandEmoji turns :shortcode: expansion of the rendered text on or off
+func (mt *MessageTemplate) SetExpandEmoji(expand bool) {
+	mt.expandEmoji = expand
+	for _, variant := range mt.variants {
+		variant.template.SetExpandEmoji(expand)
+	}
+}
+
+// SetTrimBlankLines turns blank-line collapsing of the rendered text on or off
+func (mt *MessageTemplate) SetTrimBlankLines(trim bool) {
+	mt.trimBlankLines = trim
+	for _, variant := range mt.variants {
+		variant.template.SetTrimBlankLines(trim)
+	}
+}
+
+// SetSeparator sets the line that splits a rendered message into several
+// messages; empty disables splitting
+func (mt *MessageTemplate) SetSeparator(separator string) {
+	mt.separator = strings.TrimSpace(separator)
+}
+
+// SplitParts splits a rendered message into the messages to send, in order,
+// on lines that contain only the separator. Blank parts are dropped.
+func (mt *MessageTemplate) SplitParts(message string) []string {
+	if mt.separator == "" {
+		return []string{message}
+	}
+
+	var parts []string
+	var current []string
+	flush := func() {
+		part := strings.Trim(strings.Join(current, "\n"), "\n")
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+		current = nil
+	}
+	for _, line := range strings.Split(message, "\n") {
+		if strings.TrimSpace(line) == mt.separator {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return parts
+}
+
+// Fingerprint identifies the template together with its global variables,
+// so changing a global (e.g. a promo code) changes the completed-tracker hash
+func (mt *MessageTemplate) Fingerprint() string {
+	if len(mt.globals) == 0 {
+		return mt.Content
+	}
+
+	keys := make([]string, 0, len(mt.globals))
+	for key := range mt.globals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fingerprint := mt.Content
+	for _, key := range keys {
+		fingerprint += fmt.Sprintf("|%s=%v", key, mt.globals[key])
+	}
+	return fingerprint
+}
+
+// LoadTemplate loads a template file. Front-matter at its top is not part
+// of the message; its delimiters and strict_fields apply to this file.
+func LoadTemplate(filePath string) (*MessageTemplate, error) {
+	body, settings, err := readTemplateFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	tmpl, err := parseTemplateBody(template.New("message"), body, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return &MessageTemplate{
+		tmpl:    tmpl,
+		Content: body,
+	}, nil
+}
+
+// NewColumnMessageTemplate uses each contact's value in the given CSV column
+// as its message, bypassing the template engine
+func NewColumnMessageTemplate(column string) *MessageTemplate {
+	return &MessageTemplate{
+		Content: "message_column:" + column,
+		column:  column,
+	}
+}
+
+// LoadMessageTemplate returns the configured message source: the
+// template.variants if any, the files.message_column if set, otherwise the
+// files.template_path template
+func LoadMessageTemplate(files FilesConfig, variants []TemplateVariant) (*MessageTemplate, error) {
+	if len(variants) > 0 {
+		return LoadVariantTemplates(files, variants)
+	}
+
+	if files.MessageColumn != "" {
+		Log("info", fmt.Sprintf("Using the '%s' CSV column as the message", files.MessageColumn))
+		return NewColumnMessageTemplate(files.MessageColumn), nil
+	}
+
+	if files.BaseTemplatePath != "" {
+		Log("info", fmt.Sprintf("Loading message template from %s with base layout %s", files.TemplatePath, files.BaseTemplatePath))
+		return LoadLayoutTemplate(files.BaseTemplatePath, files.TemplatePath)
+	}
+
+	Log("info", fmt.Sprintf("Loading message template from %s", files.TemplatePath))
+	return LoadTemplate(files.TemplatePath)
+}
+
+// LoadLayoutTemplate loads a campaign template that fills in the blocks of a
+// shared base layout. Rendering executes the base; the campaign file's
+// {{define "body"}} (or any other block it defines) replaces the base's
+// {{block "body" .}} default. Both files count toward the fingerprint, so
+// editing the layout also changes the completed-tracker hash.
+func LoadLayoutTemplate(basePath, filePath string) (*MessageTemplate, error) {
+	base, baseSettings, err := readTemplateFile(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base template file: %w", err)
+	}
+	content, settings, err := readTemplateFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	tmpl, err := parseTemplateBody(template.New("message"), base, baseSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base template: %w", err)
+	}
+	campaign, err := parseTemplateBody(tmpl.New("campaign"), content, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	// The base is what's executed, so it carries the campaign's strictness
+	if settings.StrictFields {
+		tmpl.Option("missingkey=error")
+	}
+	// Only the blocks the campaign defines are used; anything around them
+	// would silently never be sent
+	if campaign.Tree != nil && hasOutsideContent(campaign.Tree.Root) {
+		Log("warn", fmt.Sprintf("%s has text outside {{define}} blocks, which is ignored with a base template", filePath))
+	}
+
+	return &MessageTemplate{
+		tmpl:    tmpl,
+		Content: base + "|campaign:" + content,
+	}, nil
+}
+
+// hasOutsideContent reports whether a template has anything but whitespace
+// outside its {{define}} blocks
+func hasOutsideContent(root *parse.ListNode) bool {
+	if root == nil {
+		return false
+	}
+	for _, node := range root.Nodes {
+		if text, ok := node.(*parse.TextNode); ok && strings.TrimSpace(string(text.Text)) == "" {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// ImageCaption returns the caption for a contact's image when it isn't the
+// message itself: the contact's own "caption" column if set, otherwise the
+// caption template (image_then_text). ok is false when neither applies.
+// The message only follows the image as text with image_then_text.
+func ImageCaption(contact Contact, captionTemplate *MessageTemplate) (caption string, ok bool, err error) {
+	if caption := strings.TrimSpace(contact.FieldValue("caption")); caption != "" {
+		return caption, true, nil
+	}
+	if captionTemplate == nil {
+		return "", false, nil
+	}
+	caption, err = captionTemplate.Render(contact)
+	return caption, true, err
+}
+
+// NewMessageTemplate parses a template from a string
+func NewMessageTemplate(content string) (*MessageTemplate, error) {
+	tmpl, err := template.New("message").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return &MessageTemplate{
+		tmpl:    tmpl,
+		Content: content,
+	}, nil
+}
+
+func (mt *MessageTemplate) Render(contact Contact) (string, error) {
+	if mt.column != "" {
+		return mt.renderColumn(contact)
+	}
+	if variant := mt.variantFor(contact); variant != nil {
+		return variant.template.Render(contact)
+	}
+
+	// Create a map that includes both standard fields and dynamic fields
+	data := make(map[string]interface{})
+
+	// Globals go in first unless they should override contact fields
+	if !mt.globalsOverride {
+		for key, value := range mt.globals {
+			data[key] = value
+		}
+	}
+
+	data["Name"] = contact.Name
+	data["PhoneNumber"] = contact.PhoneNumber
+
+	// The raw fields for ranging over; a CSV column named Fields still wins
+	data["Fields"] = contact.Fields
+
+	// Add all dynamic fields from the CSV
+	for key, value := range contact.Fields {
+		data[key] = value
+	}
+
+	if mt.globalsOverride {
+		for key, value := range mt.globals {
+			data[key] = value
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := mt.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return mt.postProcess(buf.String()), nil
+}
+
+// renderColumn returns the contact's pre-rendered message with normalized
+// newlines
+func (mt *MessageTemplate) renderColumn(contact Contact) (string, error) {
+	message := contact.FieldValue(mt.column)
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("empty message in column '%s'", mt.column)
+	}
+
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	message = strings.ReplaceAll(message, "\r", "\n")
+
+	return mt.postProcess(message), nil
+}
+
+// postProcess applies the optional passes over the rendered text
+func (mt *MessageTemplate) postProcess(text string) string {
+	if mt.expandEmoji {
+		text = ExpandEmoji(text)
+	}
+	if mt.trimBlankLines {
+		text = TrimBlankLines(text)
+	}
+	return text
+}
+
+// blankLineRun matches three or more newlines in a row
+var blankLineRun = regexp.MustCompile(`\n{3,}`)
+
+// TrimBlankLines removes trailing whitespace from every line and collapses
+// runs of more than one blank line into a single blank line, cleaning up
+// after {{if}} blocks written without trim markers
+func TrimBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return blankLineRun.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
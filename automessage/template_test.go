@@ -0,0 +1,126 @@
+package automessage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrimBlankLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"single blank line kept", "Hi\n\nBye", "Hi\n\nBye"},
+		{"run of blank lines collapsed", "Hi\n\n\n\n\nBye", "Hi\n\nBye"},
+		{"several runs", "A\n\n\nB\n\n\n\nC", "A\n\nB\n\nC"},
+		{"trailing spaces removed", "Hi  \nthere\t\nBye", "Hi\nthere\nBye"},
+		{"whitespace-only lines count as blank", "Hi\n  \n\t\n \nBye", "Hi\n\nBye"},
+		{"leading newlines collapsed", "\n\n\n\nHi", "\n\nHi"},
+		{"trailing newlines collapsed", "Hi\n\n\n\n", "Hi\n\n"},
+		{"single leading and trailing newline kept", "\nHi\n", "\nHi\n"},
+		{"leading spaces kept", "  indented\n\n\n  too", "  indented\n\n  too"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrimBlankLines(tt.input); got != tt.want {
+				t.Errorf("TrimBlankLines(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTrimBlankLines(t *testing.T) {
+	mt, err := NewMessageTemplate("Hi {{.Name}},\n\n{{if .Discount}}\nYour code: {{.Discount}}\n{{end}}\n\n\nSee you soon   \n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contact := Contact{Name: "Dana", PhoneNumber: "+15102168856", Fields: map[string]string{"Discount": ""}}
+
+	untrimmed, err := mt.Render(contact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hi Dana,\n\n\n\n\nSee you soon   \n"; untrimmed != want {
+		t.Errorf("without trim_blank_lines got %q, want %q", untrimmed, want)
+	}
+
+	mt.SetTrimBlankLines(true)
+	trimmed, err := mt.Render(contact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hi Dana,\n\nSee you soon\n"; trimmed != want {
+		t.Errorf("with trim_blank_lines got %q, want %q", trimmed, want)
+	}
+}
+
+func TestRenderRangeOverFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.csv")
+	csv := "name,phone_number,item_1,item_2,quantity\nDana,+15102168856,Socks,Hat,3\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	contacts, err := ParseCSV(path, CSVOptions{NameColumns: []string{"name"}, PhoneColumns: []string{"phone_number"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 1 {
+		t.Fatalf("got %d contacts, want 1", len(contacts))
+	}
+
+	// range visits map keys in sorted order; the flattened keys still work
+	mt, err := NewMessageTemplate("{{.Name}}:{{range $k, $v := .Fields}} {{$k}}={{$v}}{{end}} ({{.Quantity}})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := mt.Render(contacts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Dana: Item_1=Socks Item_2=Hat Quantity=3 (3)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLoadMessageTemplateWithBase(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.txt", "Hi {{.Name}},\n{{block \"body\" .}}Thanks for shopping with us.{{end}}\n-- The Shop")
+	child := write("order.txt", "{{define \"body\"}}Order {{.Order}} has shipped.{{end}}")
+	empty := write("empty.txt", "")
+
+	contact := Contact{Name: "Dana", PhoneNumber: "+15102168856", Fields: map[string]string{"Order": "A-17"}}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"child overrides block", child, "Hi Dana,\nOrder A-17 has shipped.\n-- The Shop"},
+		{"block default", empty, "Hi Dana,\nThanks for shopping with us.\n-- The Shop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt, err := LoadMessageTemplate(FilesConfig{TemplatePath: tt.template, BaseTemplatePath: base}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := mt.Render(contact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package automessage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// fieldTransforms are the named cleanups that files.transforms can apply to
+// a column while the CSV is read
+var fieldTransforms = map[string]func(string) string{
+	"trim":        strings.TrimSpace,
+	"upper":       strings.ToUpper,
+	"lower":       strings.ToLower,
+	"title":       titleCase,
+	"digits_only": DigitsOnly,
+}
+
+// maxTransformSamples is how many before/after pairs are logged per run
+const maxTransformSamples = 5
+
+// validateTransforms checks that every configured transform exists
+func validateTransforms(transforms map[string][]string) error {
+	for column, names := range transforms {
+		for _, name := range names {
+			if _, ok := fieldTransforms[name]; !ok {
+				return fmt.Errorf("unknown transform %q for column %q (available: trim, upper, lower, title, digits_only)", name, column)
+			}
+		}
+	}
+	return nil
+}
+
+// columnTransforms resolves the configured transforms to column indices,
+// matching column names case-insensitively
+func columnTransforms(headers []string, transforms map[string][]string) map[int][]string {
+	byIndex := make(map[int][]string)
+	for column, names := range transforms {
+		for i, header := range headers {
+			if strings.EqualFold(header, strings.TrimSpace(column)) {
+				byIndex[i] = names
+			}
+		}
+	}
+	return byIndex
+}
+
+// applyTransforms runs the named transforms over a value in order
+func applyTransforms(value string, names []string) string {
+	for _, name := range names {
+		value = fieldTransforms[name](value)
+	}
+	return value
+}
+
+// titleCase lowercases a value and capitalizes the first letter of each word
+func titleCase(s string) string {
+	runes := []rune(strings.ToLower(s))
+	startOfWord := true
+	for i, r := range runes {
+		if startOfWord && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		startOfWord = !unicode.IsLetter(r) && r != '\''
+	}
+	return string(runes)
+}
+
+// DigitsOnly drops everything except ASCII digits, converting other decimal
+// digits first
+func DigitsOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, ASCIIDigits(s))
+}
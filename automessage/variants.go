@@ -0,0 +1,105 @@
+package automessage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// messageVariant is one weighted template of an A/B test (template.variants)
+type messageVariant struct {
+	name     string
+	weight   float64
+	template *MessageTemplate
+}
+
+// LoadVariantTemplates loads every template.variants entry the way a single
+// files.template_path would be loaded, including the base layout, and
+// combines them into one message template that picks a variant per contact
+func LoadVariantTemplates(files FilesConfig, variants []TemplateVariant) (*MessageTemplate, error) {
+	loaded := make([]messageVariant, 0, len(variants))
+	for _, variant := range variants {
+		variantFiles := files
+		variantFiles.TemplatePath = variant.Path
+		Log("info", fmt.Sprintf("Template variant %s (weight %g):", variant.Name, variant.Weight))
+		t, err := LoadMessageTemplate(variantFiles, nil)
+		if err != nil {
+			return nil, fmt.Errorf("template variant %s: %w", variant.Name, err)
+		}
+		loaded = append(loaded, messageVariant{name: variant.Name, weight: variant.Weight, template: t})
+	}
+	return NewVariantMessageTemplate(loaded), nil
+}
+
+// NewVariantMessageTemplate renders each contact with the variant
+// VariantFor assigns it. Every variant's content, name and weight count
+// toward the fingerprint.
+func NewVariantMessageTemplate(variants []messageVariant) *MessageTemplate {
+	var content []string
+	for _, variant := range variants {
+		content = append(content, fmt.Sprintf("variant:%s:%g:%s", variant.name, variant.weight, variant.template.Content))
+	}
+	return &MessageTemplate{
+		Content:  strings.Join(content, "|"),
+		variants: variants,
+	}
+}
+
+// VariantFor returns the name of the variant a contact gets, or "" without
+// template.variants. The phone number (a group's name) is hashed into the
+// weighted distribution, so a contact keeps its variant across runs.
+func (mt *MessageTemplate) VariantFor(contact Contact) string {
+	if variant := mt.variantFor(contact); variant != nil {
+		return variant.name
+	}
+	return ""
+}
+
+func (mt *MessageTemplate) variantFor(contact Contact) *messageVariant {
+	if len(mt.variants) == 0 {
+		return nil
+	}
+
+	key := "phone:" + CleanPhoneNumber(contact.PhoneNumber)
+	if contact.Type == ContactGroup {
+		key = "group:" + contact.Name
+	}
+	sum := sha256.Sum256([]byte(key))
+	point := float64(binary.BigEndian.Uint64(sum[:8])>>11) / float64(1<<53) // Uniform in [0, 1)
+
+	var total float64
+	for _, variant := range mt.variants {
+		total += variant.weight
+	}
+	point *= total
+	for i := range mt.variants {
+		if point < mt.variants[i].weight {
+			return &mt.variants[i]
+		}
+		point -= mt.variants[i].weight
+	}
+	return &mt.variants[len(mt.variants)-1]
+}
+
+// HasVariants reports whether the template picks among template.variants
+func (mt *MessageTemplate) HasVariants() bool {
+	return len(mt.variants) > 0
+}
+
+// VariantNames returns the variant names in config order
+func (mt *MessageTemplate) VariantNames() []string {
+	names := make([]string, 0, len(mt.variants))
+	for _, variant := range mt.variants {
+		names = append(names, variant.name)
+	}
+	return names
+}
+
+// variantName is the default name of a variant: its file name without the
+// extension
+func variantName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
@@ -0,0 +1,335 @@
+package automessage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vcardSkipped lists vCard properties that never become template fields:
+// structure, identifiers and binary data
+var vcardSkipped = map[string]bool{
+	"BEGIN": true, "END": true, "VERSION": true, "PRODID": true, "REV": true,
+	"FN": true, "N": true, "TEL": true,
+	"PHOTO": true, "LOGO": true, "SOUND": true, "KEY": true,
+}
+
+// vcardProperty is one unfolded "GROUP.NAME;PARAMS:value" line
+type vcardProperty struct {
+	Name   string
+	Types  []string // Lowercased TYPE parameters, e.g. cell, home, pref
+	Value  string
+	Params map[string]string
+}
+
+// LoadContacts reads a contact list, picking the parser from the file
+// extension: .vcf/.vcard address books or CSV for anything else
+func LoadContacts(filePath string, opts CSVOptions) ([]Contact, error) {
+	if IsVCardPath(filePath) {
+		return ParseVCard(filePath, opts)
+	}
+	return ParseCSV(filePath, opts)
+}
+
+// IsVCardPath reports whether a contact list path is a vCard address book
+func IsVCardPath(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".vcf", ".vcard":
+		return true
+	}
+	return false
+}
+
+// ParseVCard reads every contact in a vCard file. FN becomes the name, the
+// preferred TEL the phone number and other text properties become fields
+// (e.g. EMAIL -> {{.Email}}, ORG -> {{.Org}}). Cards without a phone number
+// are skipped with a warning.
+func ParseVCard(filePath string, opts CSVOptions) ([]Contact, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vCard file: %w", err)
+	}
+	defer file.Close()
+
+	cards, err := readVCards(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vCard file: %w", err)
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("vCard file contains no contacts")
+	}
+
+	var contacts []Contact
+	for i, card := range cards {
+		contact, err := vcardContact(card, opts)
+		if err != nil {
+			return nil, fmt.Errorf("card %d: %w", i+1, err)
+		}
+		if contact.PhoneNumber == "" {
+			Log("warn", fmt.Sprintf("Card %d (%s) has no phone number, skipping", i+1, contact.Name))
+			continue
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+// readVCards splits a vCard stream into cards of unfolded properties
+func readVCards(r io.Reader) ([][]vcardProperty, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	// Unfold continuation lines first: RFC 6350 folds with a leading space
+	// or tab, vCard 2.1 quoted-printable values with a trailing "="
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if n := len(lines); n > 0 {
+			last := lines[n-1]
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				lines[n-1] = last + line[1:]
+				continue
+			}
+			if strings.HasSuffix(last, "=") && strings.Contains(strings.ToUpper(last), "QUOTED-PRINTABLE") {
+				lines[n-1] = last + "\n" + line
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var cards [][]vcardProperty
+	var current []vcardProperty
+	inCard := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, ok := parseVCardLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VCARD"):
+			inCard = true
+			current = nil
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VCARD"):
+			if inCard {
+				cards = append(cards, current)
+			}
+			inCard = false
+		case inCard:
+			current = append(current, prop)
+		}
+	}
+
+	return cards, nil
+}
+
+// parseVCardLine splits an unfolded line into name, parameters and value
+func parseVCardLine(line string) (vcardProperty, bool) {
+	colon := strings.Index(line, ":")
+	if colon == -1 {
+		return vcardProperty{}, false
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	name := strings.ToUpper(parts[0])
+	if dot := strings.LastIndex(name, "."); dot != -1 {
+		name = name[dot+1:] // Drop the group, e.g. "item1.TEL"
+	}
+
+	prop := vcardProperty{Name: name, Params: make(map[string]string)}
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			// vCard 2.1 allows bare types and encodings, e.g.
+			// "TEL;CELL:..." or "FN;QUOTED-PRINTABLE:..."
+			key, value = "TYPE", param
+			switch strings.ToUpper(strings.TrimSpace(param)) {
+			case "QUOTED-PRINTABLE", "BASE64", "8BIT":
+				key = "ENCODING"
+			}
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if key == "TYPE" {
+			for _, t := range strings.Split(value, ",") {
+				prop.Types = append(prop.Types, strings.ToLower(strings.TrimSpace(t)))
+			}
+			continue
+		}
+		prop.Params[key] = value
+	}
+
+	value := line[colon+1:]
+	if strings.EqualFold(prop.Params["ENCODING"], "QUOTED-PRINTABLE") {
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(value)))
+		if err == nil {
+			value = string(decoded)
+		}
+	}
+	prop.Value = value
+
+	return prop, true
+}
+
+// vcardContact builds a contact from a card's properties
+func vcardContact(card []vcardProperty, opts CSVOptions) (Contact, error) {
+	contact := Contact{Type: ContactPerson, Fields: make(map[string]string)}
+
+	var structuredName string
+	var phones []vcardProperty
+	for _, prop := range card {
+		switch prop.Name {
+		case "FN":
+			contact.Name = strings.TrimSpace(unescapeVCard(prop.Value))
+		case "N":
+			structuredName = vcardStructuredName(prop.Value)
+		case "TEL":
+			phones = append(phones, prop)
+		default:
+			if vcardSkipped[prop.Name] || strings.EqualFold(prop.Params["ENCODING"], "b") ||
+				strings.EqualFold(prop.Params["ENCODING"], "base64") {
+				continue
+			}
+			fieldName := vcardFieldName(prop.Name)
+			if _, exists := contact.Fields[fieldName]; exists {
+				continue // Keep the first EMAIL, ADR, ...
+			}
+			contact.Fields[fieldName] = applyColumnTransforms(vcardJoinComponents(prop.Value), opts.Transforms, fieldName)
+		}
+	}
+
+	if phone := pickVCardPhone(phones, opts.VCardPhoneTypes); phone != "" {
+		phone = applyColumnTransforms(phone, opts.Transforms, append([]string{"tel"}, opts.PhoneColumns...)...)
+		contact.PhoneNumber = ToInternational(phone, opts.National)
+	}
+
+	if contact.Name == "" {
+		contact.Name = structuredName
+	}
+	if contact.Name == "" {
+		if opts.RequireName {
+			return Contact{}, fmt.Errorf("contact %s has no FN or N name", contact.PhoneNumber)
+		}
+		contact.Name = contact.PhoneNumber
+	}
+	contact.Name = applyColumnTransforms(contact.Name, opts.Transforms, append([]string{"fn"}, opts.NameColumns...)...)
+
+	return contact, nil
+}
+
+// pickVCardPhone returns the first TEL whose type matches the earliest
+// preferred type, or the first TEL if none matches
+func pickVCardPhone(phones []vcardProperty, preferred []string) string {
+	if len(phones) == 0 {
+		return ""
+	}
+	for _, want := range preferred {
+		want = strings.ToLower(strings.TrimSpace(want))
+		for _, phone := range phones {
+			for _, t := range phone.Types {
+				if t == want {
+					return vcardPhoneValue(phone.Value)
+				}
+			}
+		}
+	}
+	return vcardPhoneValue(phones[0].Value)
+}
+
+// vcardPhoneValue strips the "tel:" URI scheme vCard 4.0 uses for numbers
+func vcardPhoneValue(value string) string {
+	value = strings.TrimSpace(unescapeVCard(value))
+	if len(value) > 4 && strings.EqualFold(value[:4], "tel:") {
+		value = value[4:]
+	}
+	return value
+}
+
+// vcardStructuredName turns "Family;Given;Middle;Prefix;Suffix" into
+// "Given Family"
+func vcardStructuredName(value string) string {
+	components := splitVCardComponents(value)
+	var parts []string
+	if len(components) > 1 && components[1] != "" {
+		parts = append(parts, components[1])
+	}
+	if len(components) > 0 && components[0] != "" {
+		parts = append(parts, components[0])
+	}
+	return strings.Join(parts, " ")
+}
+
+// vcardJoinComponents renders a structured value such as ORG or ADR as a
+// comma-separated list of its non-empty parts
+func vcardJoinComponents(value string) string {
+	var parts []string
+	for _, component := range splitVCardComponents(value) {
+		if component != "" {
+			parts = append(parts, component)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// splitVCardComponents splits a value on unescaped semicolons and unescapes
+// each part
+func splitVCardComponents(value string) []string {
+	var components []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			components = append(components, strings.TrimSpace(unescapeVCard(current.String())))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	components = append(components, strings.TrimSpace(unescapeVCard(current.String())))
+	return components
+}
+
+// vcardEscapes undoes vCard text escaping
+var vcardEscapes = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeVCard(value string) string {
+	return vcardEscapes.Replace(value)
+}
+
+// vcardFieldName turns a property name into a template field name the way
+// CSV headers are, e.g. "EMAIL" -> "Email", "X-COMPANY-ID" -> "X-company-id"
+func vcardFieldName(name string) string {
+	lower := strings.ToLower(name)
+	return strings.ToUpper(lower[:1]) + lower[1:]
+}
+
+// applyColumnTransforms runs the files.transforms configured for the first
+// of the column names that has any, matched case-insensitively
+func applyColumnTransforms(value string, transforms map[string][]string, columns ...string) string {
+	for _, column := range columns {
+		for name, names := range transforms {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column)) {
+				return applyTransforms(value, names)
+			}
+		}
+	}
+	return value
+}
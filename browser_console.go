@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+
+	"whatsapp-automation/automessage"
+)
+
+// enableConsoleForwarding subscribes to the browser's console and log events
+// and forwards them into our logger at debug level
+func (c *WhatsAppClient) enableConsoleForwarding() error {
+	chromedp.ListenTarget(c.ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			args := make([]string, 0, len(ev.Args))
+			for _, arg := range ev.Args {
+				args = append(args, formatRemoteObject(arg))
+			}
+			automessage.Log("debug", fmt.Sprintf("[browser console.%s] %s", ev.Type, strings.Join(args, " ")))
+		case *cdplog.EventEntryAdded:
+			if ev.Entry == nil {
+				return
+			}
+			msg := fmt.Sprintf("[browser %s/%s] %s", ev.Entry.Source, ev.Entry.Level, ev.Entry.Text)
+			if ev.Entry.URL != "" {
+				msg += fmt.Sprintf(" (%s:%d)", ev.Entry.URL, ev.Entry.LineNumber)
+			}
+			automessage.Log("debug", msg)
+		}
+	})
+
+	// Runtime events are enabled by chromedp itself, the Log domain is not
+	if err := chromedp.Run(c.ctx, cdplog.Enable()); err != nil {
+		return fmt.Errorf("failed to enable browser log domain: %w", err)
+	}
+
+	return nil
+}
+
+// formatRemoteObject renders a console argument as readable text
+func formatRemoteObject(obj *runtime.RemoteObject) string {
+	if obj == nil {
+		return ""
+	}
+	if len(obj.Value) > 0 {
+		value := string(obj.Value)
+		// Strip the JSON quotes from plain string values
+		if obj.Type == runtime.TypeString && len(value) >= 2 {
+			value = strings.Trim(value, `"`)
+		}
+		return value
+	}
+	if obj.UnserializableValue != "" {
+		return string(obj.UnserializableValue)
+	}
+	if obj.Description != "" {
+		return obj.Description
+	}
+	return string(obj.Type)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"whatsapp-automation/automessage"
+)
+
+// markProfileExitedCleanly clears the crash marker Chrome leaves in the
+// profile's Preferences after a crash or a killed run. Without it the next
+// launch shows the "Restore pages?" bubble, which can take focus and get in
+// the way of navigation. A missing or unreadable Preferences file is left
+// alone; the launch flags cover most cases anyway.
+func markProfileExitedCleanly(userDataDir string) {
+	if userDataDir == "" {
+		return
+	}
+
+	path := filepath.Join(userDataDir, "Default", "Preferences")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	// Numbers are kept as written, large ones must not go through float64
+	var prefs map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&prefs); err != nil {
+		automessage.Log("debug", fmt.Sprintf("Could not parse %s, leaving it alone: %v", path, err))
+		return
+	}
+	profile, ok := prefs["profile"].(map[string]interface{})
+	if !ok || profile["exit_type"] == nil || profile["exit_type"] == "Normal" {
+		return
+	}
+
+	automessage.Log("info", "The previous browser session did not exit cleanly, suppressing Chrome's \"Restore pages?\" prompt")
+	profile["exit_type"] = "Normal"
+	profile["exited_cleanly"] = true
+	updated, err := json.Marshal(prefs)
+	if err != nil {
+		automessage.Log("warn", fmt.Sprintf("Failed to update browser preferences: %v", err))
+		return
+	}
+
+	// Write next to the original and rename, so a failure can't leave a
+	// truncated Preferences behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, updated, 0600); err != nil {
+		automessage.Log("warn", fmt.Sprintf("Failed to update browser preferences: %v", err))
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		automessage.Log("warn", fmt.Sprintf("Failed to update browser preferences: %v", err))
+	}
+}
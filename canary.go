@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"whatsapp-automation/automessage"
+)
+
+// canaryContact builds the contact for files.canary_phone, with field names
+// capitalized the same way ParseCSV does
+func canaryContact(files automessage.FilesConfig) automessage.Contact {
+	contact := automessage.Contact{
+		Name:        files.CanaryName,
+		PhoneNumber: automessage.ToInternational(files.CanaryPhone, files.NationalToInternational),
+		Fields:      make(map[string]string, len(files.CanaryFields)),
+	}
+	if contact.Name == "" {
+		contact.Name = contact.PhoneNumber
+	}
+	for key, value := range files.CanaryFields {
+		if key != "" {
+			key = strings.ToUpper(key[:1]) + key[1:]
+		}
+		contact.Fields[key] = value
+	}
+	return contact
+}
+
+// RunCanary sends the rendered campaign to files.canary_phone before the real
+// list, exactly as a contact would receive it (opener, attachment, caption,
+// carousel and split parts included). With files.canary_confirm the operator
+// must also confirm on the terminal that it looked right. Any error means the
+// run must not go ahead.
+func RunCanary(config *automessage.Config, client *WhatsAppClient, templates *automessage.RunTemplates) error {
+	contact := canaryContact(config.Files)
+
+	send, err := composeContactSend(contact, config, templates)
+	if err != nil {
+		return fmt.Errorf("failed to compose canary message: %w", err)
+	}
+	if send.TextOnlyReason != "" {
+		automessage.Log(send.TextOnlyLevel, fmt.Sprintf("Canary gets text only: %s", send.TextOnlyReason))
+	}
+	opts := send.Opts
+	opts.Recipient = contact.Name
+
+	automessage.Log("info", fmt.Sprintf("Canary: sending the campaign to %s (%s) before the full list", contact.Name, contact.PhoneNumber))
+	sentFirst := 1
+	if len(send.Carousel) > 0 {
+		if err := sendCarousel(client, contact.PhoneNumber, send.Carousel, opts); err != nil {
+			return fmt.Errorf("canary send to %s failed: %w", contact.PhoneNumber, err)
+		}
+		sentFirst = len(send.Carousel)
+	} else if err := client.SendMessage(contact.PhoneNumber, send.First, opts); err != nil {
+		return fmt.Errorf("canary send to %s failed: %w", contact.PhoneNumber, err)
+	}
+	for i, followUp := range send.FollowUps {
+		if err := client.SendFollowUp(contact.PhoneNumber, followUp, SendOptions{Recipient: contact.Name}); err != nil {
+			return fmt.Errorf("canary message %d/%d to %s failed: %w", sentFirst+i+1, sentFirst+len(send.FollowUps), contact.PhoneNumber, err)
+		}
+	}
+
+	// In a sandbox nothing reached the phone, so there is nothing to check
+	if config.Sandbox() {
+		automessage.Log("info", "[SANDBOX] Canary message composed without sending")
+		return nil
+	}
+	automessage.Log("info", "✓ Canary message sent")
+
+	if config.Files.CanaryConfirm {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("files.canary_confirm needs a terminal to confirm the canary message")
+		}
+		if !promptYesNo(fmt.Sprintf("Check the message on %s. Did it look right? Continue with the full list?", contact.PhoneNumber), false) {
+			return fmt.Errorf("canary message was not confirmed")
+		}
+		automessage.Log("info", "Canary message confirmed by operator")
+	}
+
+	return nil
+}
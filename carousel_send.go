@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"whatsapp-automation/automessage"
+)
+
+// sendCarousel sends a contact's images in order, each as its own message
+// with the usual pacing in between. A failure after the first image is a
+// partial send naming the image, so it's clear where to pick up by hand.
+func sendCarousel(client *WhatsAppClient, phoneNumber string, carousel []automessage.CarouselSend, opts SendOptions) error {
+	for i, image := range carousel {
+		imageOpts := opts
+		imageOpts.ImagePath = image.Path
+		imageOpts.ImageRequired = true
+		if i > 0 {
+			imageOpts.Opener = ""
+			imageOpts.ExtraDelay = 0
+			imageOpts.Review = false // The first image is the contact's review
+		}
+
+		err := client.SendMessage(phoneNumber, image.Caption, imageOpts)
+		switch {
+		case err == nil:
+		case i > 0 && errors.Is(err, ErrAlreadyInChat):
+			// This image went out in an earlier run
+		case i == 0 || errors.Is(err, ErrSendUnverified):
+			return err
+		default:
+			return fmt.Errorf("%w: image %d/%d (%s): %v", ErrPartialSend, i+1, len(carousel), filepath.Base(image.Path), err)
+		}
+	}
+	return nil
+}
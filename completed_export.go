@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"whatsapp-automation/automessage"
+)
+
+// ExportCompleted writes every contact of the input list that the completed
+// tracker has as sent to outPath, in the same shape as -dump-contacts.
+// Contacts are matched by tracker hash first; completed entries whose hash
+// no longer matches (e.g. the template or the row changed since) fall back
+// to the phone number. Returns how many contacts were written and how many
+// completed entries matched no input row.
+func ExportCompleted(config *automessage.Config, outPath string) (exported, unmatched int, err error) {
+	csvOpts := automessage.CSVOptionsFor(config)
+	csvOpts.BadRowsPath = ""
+	contacts, err := automessage.LoadContacts(config.Files.CSVPath, csvOpts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load contacts: %w", err)
+	}
+
+	// The hash includes the rendered template, so load it as a run would
+	templates, err := automessage.LoadRunTemplates(config)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tracker, err := automessage.NewCompletedTracker(config.Files.CompletedCSVPath, templates.Message, config.Tracker.KeyStrategy)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load completed tracker: %w", err)
+	}
+	defer tracker.Close()
+
+	entries := tracker.Entries()
+	matchedHashes := make(map[string]bool, len(entries))
+	exportedRows := make(map[int]bool)
+	var matched []automessage.Contact
+	for i, contact := range contacts {
+		hash := tracker.Hash(contact)
+		if tracker.IsCompleted(contact) && !matchedHashes[hash] {
+			matchedHashes[hash] = true
+			exportedRows[i] = true
+			matched = append(matched, contact)
+		}
+	}
+
+	// Fall back to the phone number for entries from an older template
+	byPhone := make(map[string]int)
+	for i, contact := range contacts {
+		phone := automessage.CleanPhoneNumber(contact.PhoneNumber)
+		if _, seen := byPhone[phone]; phone != "" && !seen {
+			byPhone[phone] = i
+		}
+	}
+	for _, entry := range entries {
+		if matchedHashes[entry.Hash] {
+			continue
+		}
+		i, ok := byPhone[automessage.CleanPhoneNumber(entry.PhoneNumber)]
+		if !ok || automessage.CleanPhoneNumber(entry.PhoneNumber) == "" {
+			unmatched++
+			automessage.Log("debug", fmt.Sprintf("Completed entry %s (%s) matches no input row", entry.Name, entry.PhoneNumber))
+			continue
+		}
+		if !exportedRows[i] {
+			exportedRows[i] = true
+			matched = append(matched, contacts[i])
+		}
+	}
+
+	if err := WriteContactsCSV(outPath, matched); err != nil {
+		return 0, 0, err
+	}
+	return len(matched), unmatched, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"whatsapp-automation/automessage"
+)
+
+// ContactSend is what a contact gets, composed from the run's templates and
+// media settings before any browser work. The run, the canary and
+// -render-only all use it, so they can't disagree about a contact.
+type ContactSend struct {
+	Message         string                     // The rendered message
+	Parts           []string                   // The message split by template.message_separator
+	First           string                     // Sent first: the image caption or the first part
+	FollowUps       []string                   // Sent as text after the first message or the carousel
+	SeparateCaption bool                       // First is the caption column or template, not the message
+	Carousel        []automessage.CarouselSend // files.images, sent before the message parts
+	Opts            SendOptions                // Opener, media and whether the image is required
+
+	// Why a contact that would have had media gets text only, and the level
+	// to log it at; empty when nothing was downgraded
+	TextOnlyReason string
+	TextOnlyLevel  string
+}
+
+// ImageSend reports whether the first message is sent with an attachment
+func (s *ContactSend) ImageSend() bool {
+	return !s.Opts.TextOnly && s.Opts.ImagePath != ""
+}
+
+// composeContactSend renders a contact's message, opener, caption and
+// carousel, and resolves the file attached to the first message. A missing
+// or unrenderable per-contact file downgrades the contact to text; anything
+// else that can't be rendered is an error.
+func composeContactSend(contact automessage.Contact, config *automessage.Config, templates *automessage.RunTemplates) (*ContactSend, error) {
+	message, err := templates.Message.Render(contact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	send := &ContactSend{
+		Message:       message,
+		Parts:         templates.Message.SplitParts(message),
+		TextOnlyLevel: "info",
+	}
+	if len(send.Parts) > 0 {
+		send.First, send.FollowUps = send.Parts[0], send.Parts[1:]
+	}
+
+	if templates.Opener != nil {
+		send.Opts.Opener, err = templates.Opener.Render(contact)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render opener: %w", err)
+		}
+	}
+
+	send.resolveMedia(contact, config, templates)
+
+	// files.images sends a carousel of captioned images, one message each,
+	// and every part of the message then follows as text
+	send.Carousel, err = automessage.RenderCarousel(templates.Carousel, contact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render image captions: %w", err)
+	}
+	if len(send.Carousel) > 0 {
+		send.FollowUps = send.Parts
+	}
+
+	// A per-contact caption column or the caption template captions the
+	// image instead of the message. Only image_then_text sends the message
+	// after it; a caption column alone sends just the captioned image.
+	if send.ImageSend() {
+		caption, ok, err := automessage.ImageCaption(contact, templates.Caption)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render caption: %w", err)
+		}
+		if ok {
+			send.First, send.FollowUps = caption, nil
+			send.SeparateCaption = true
+			if templates.Caption != nil {
+				// The text follows the image, so it must not stand in for a
+				// failed image: the contact fails instead
+				send.FollowUps = send.Parts
+				send.Opts.ImageRequired = true
+			}
+		}
+	}
+
+	// An image may go out on its own, anything else needs text
+	if strings.TrimSpace(send.First) == "" && len(send.Carousel) == 0 && !(send.ImageSend() && config.Template.AllowEmptyCaption) {
+		return nil, fmt.Errorf("rendered message is empty")
+	}
+	return send, nil
+}
+
+// resolveMedia picks the file attached to the contact's first message:
+// the attachment column, else image_path_template, else the campaign's
+// image or video. The media column opts a contact out of all of them.
+func (s *ContactSend) resolveMedia(contact automessage.Contact, config *automessage.Config, templates *automessage.RunTemplates) {
+	attachment := strings.TrimSpace(contact.FieldValue(attachmentColumn))
+	if contact.Media == automessage.MediaText || contact.Media == automessage.MediaNone {
+		s.Opts.TextOnly = true
+		if config.Files.ImagePath != "" || config.Files.VideoPath != "" || templates.ImagePath != nil || attachment != "" {
+			s.TextOnlyReason = fmt.Sprintf("media column is %s", contact.Media)
+		}
+		return
+	}
+	if len(templates.Carousel) > 0 {
+		return // The carousel replaces every other attachment
+	}
+
+	// An attachment column gives the contact its own file, sent as an
+	// image, video, document or audio file depending on its extension
+	if attachment != "" {
+		kind, ok := attachmentKindFor(attachment)
+		if !ok {
+			s.downgrade(fmt.Sprintf("attachment column file %s is not a supported file type", attachment), "warn")
+		} else if _, err := os.Stat(attachment); err != nil {
+			s.downgrade(fmt.Sprintf("attachment column file %s not found", attachment), "warn")
+		} else {
+			s.Opts.ImagePath, s.Opts.Attachment = attachment, kind
+		}
+		return
+	}
+
+	// A rendered image path decides per contact whether an image is attached
+	if templates.ImagePath != nil {
+		imagePath, err := templates.ImagePath.Render(contact)
+		imagePath = strings.TrimSpace(imagePath)
+		switch {
+		case err != nil:
+			s.downgrade(fmt.Sprintf("image_path_template failed to render: %v", err), "warn")
+		case imagePath == "":
+			s.downgrade("image_path_template rendered empty", "info")
+		default:
+			if _, statErr := os.Stat(imagePath); statErr != nil {
+				s.downgrade(fmt.Sprintf("image_path_template file %s not found", imagePath), "warn")
+			} else {
+				s.Opts.ImagePath = imagePath
+				if kind, _ := attachmentKindFor(imagePath); kind == AttachmentVideo {
+					s.Opts.Attachment = AttachmentVideo
+				}
+			}
+		}
+		return
+	}
+
+	if config.Files.VideoPath != "" {
+		s.Opts.ImagePath, s.Opts.Attachment = config.Files.VideoPath, AttachmentVideo
+	} else {
+		s.Opts.ImagePath = config.Files.ImagePath
+	}
+}
+
+// downgrade sends the contact text only, recording why
+func (s *ContactSend) downgrade(reason, level string) {
+	s.Opts.TextOnly = true
+	s.Opts.ImagePath, s.Opts.Attachment = "", ""
+	s.TextOnlyReason, s.TextOnlyLevel = reason, level
+}
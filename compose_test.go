@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"whatsapp-automation/automessage"
+)
+
+func TestComposeContactSendMedia(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "a.jpg")
+	doc := filepath.Join(dir, "a.pdf")
+	for _, path := range []string{image, doc} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message, err := automessage.NewMessageTemplate("Hi {{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imagePath, err := automessage.NewMessageTemplate(filepath.Join(dir, "{{.Name}}.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		files          automessage.FilesConfig
+		imagePath      *automessage.MessageTemplate
+		contact        automessage.Contact
+		wantPath       string
+		wantAttachment AttachmentKind
+		wantDowngraded bool
+	}{
+		{"campaign image", automessage.FilesConfig{ImagePath: image}, nil, automessage.Contact{Name: "a"}, image, "", false},
+		{"campaign video", automessage.FilesConfig{VideoPath: "clip.mp4"}, nil, automessage.Contact{Name: "a"}, "clip.mp4", AttachmentVideo, false},
+		{"media column", automessage.FilesConfig{ImagePath: image}, nil, automessage.Contact{Name: "a", Media: automessage.MediaText}, "", "", true},
+		{"text campaign", automessage.FilesConfig{}, nil, automessage.Contact{Name: "a", Media: automessage.MediaText}, "", "", false},
+		{"image path template", automessage.FilesConfig{}, imagePath, automessage.Contact{Name: "a"}, image, "", false},
+		{"image path template missing", automessage.FilesConfig{}, imagePath, automessage.Contact{Name: "b"}, "", "", true},
+		{"attachment column", automessage.FilesConfig{ImagePath: image}, nil,
+			automessage.Contact{Name: "a", Fields: map[string]string{"Attachment": doc}}, doc, AttachmentDocument, false},
+		{"attachment column missing", automessage.FilesConfig{ImagePath: image}, nil,
+			automessage.Contact{Name: "a", Fields: map[string]string{"Attachment": filepath.Join(dir, "b.pdf")}}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &automessage.Config{Files: tt.files}
+			send, err := composeContactSend(tt.contact, config, &automessage.RunTemplates{Message: message, ImagePath: tt.imagePath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if send.Opts.ImagePath != tt.wantPath || send.Opts.Attachment != tt.wantAttachment {
+				t.Errorf("attachment = %q (%q), want %q (%q)", send.Opts.ImagePath, send.Opts.Attachment, tt.wantPath, tt.wantAttachment)
+			}
+			if downgraded := send.TextOnlyReason != ""; downgraded != tt.wantDowngraded {
+				t.Errorf("downgraded = %v (%q), want %v", downgraded, send.TextOnlyReason, tt.wantDowngraded)
+			}
+			if send.ImageSend() != (tt.wantPath != "") {
+				t.Errorf("ImageSend() = %v with attachment %q", send.ImageSend(), send.Opts.ImagePath)
+			}
+		})
+	}
+}
+
+func TestComposeContactSendCaptionColumn(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "a.jpg")
+	if err := os.WriteFile(image, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	message, err := automessage.NewMessageTemplate("Hi {{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caption, err := automessage.NewMessageTemplate("Caption for {{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contact := automessage.Contact{Name: "Dana", Fields: map[string]string{"Caption": "Just for Dana"}}
+	config := &automessage.Config{Files: automessage.FilesConfig{ImagePath: image}}
+
+	tests := []struct {
+		name          string
+		captionTmpl   *automessage.MessageTemplate
+		contact       automessage.Contact
+		wantFirst     string
+		wantFollowUps int
+		wantRequired  bool
+	}{
+		{"caption column alone", nil, contact, "Just for Dana", 0, false},
+		{"caption column with image_then_text", caption, contact, "Just for Dana", 1, true},
+		{"image_then_text", caption, automessage.Contact{Name: "Dana"}, "Caption for Dana", 1, true},
+		{"no caption", nil, automessage.Contact{Name: "Dana"}, "Hi Dana", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send, err := composeContactSend(tt.contact, config, &automessage.RunTemplates{Message: message, Caption: tt.captionTmpl})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if send.First != tt.wantFirst || len(send.FollowUps) != tt.wantFollowUps || send.Opts.ImageRequired != tt.wantRequired {
+				t.Errorf("got first %q, %d follow-ups, image required %v; want %q, %d, %v",
+					send.First, len(send.FollowUps), send.Opts.ImageRequired, tt.wantFirst, tt.wantFollowUps, tt.wantRequired)
+			}
+		})
+	}
+}
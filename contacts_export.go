@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"whatsapp-automation/automessage"
+)
+
+// WriteContactsCSV writes contacts to a CSV with the resolved name, the
+// normalized phone number, the media preference, the retry override and
+// every dynamic field
+func WriteContactsCSV(filePath string, contacts []automessage.Contact) error {
+	// Collect the union of field names so every row has the same columns
+	fieldSet := make(map[string]bool)
+	for _, contact := range contacts {
+		for key := range contact.Fields {
+			fieldSet[key] = true
+		}
+	}
+	fieldNames := make([]string, 0, len(fieldSet))
+	for key := range fieldSet {
+		fieldNames = append(fieldNames, key)
+	}
+	sort.Strings(fieldNames)
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create contacts CSV: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	header := append([]string{"name", "phone_number", "type", "media", "max_retries"}, fieldNames...)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, contact := range contacts {
+		record := []string{
+			contact.Name,
+			automessage.NormalizePhoneNumber(contact.PhoneNumber),
+			string(contact.Type),
+			string(contact.Media),
+			"",
+		}
+		if contact.MaxRetries != nil {
+			record[4] = strconv.Itoa(*contact.MaxRetries)
+		}
+		for _, key := range fieldNames {
+			record = append(record, contact.Fields[key])
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush contacts CSV: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// deliveryHealth is a sliding window over the most recent sends, recording
+// which were not delivered when checked
+type deliveryHealth struct {
+	recent []bool // Oldest first, true if the send was not delivered
+}
+
+// record adds a send's outcome, keeping only the last size sends
+func (h *deliveryHealth) record(undelivered bool, size int) {
+	h.recent = append(h.recent, undelivered)
+	if len(h.recent) > size {
+		h.recent = h.recent[len(h.recent)-size:]
+	}
+}
+
+// counts returns how many sends in the window were not delivered, out of
+// how many
+func (h *deliveryHealth) counts() (undelivered, total int) {
+	for _, u := range h.recent {
+		if u {
+			undelivered++
+		}
+	}
+	return undelivered, len(h.recent)
+}
+
+func (h *deliveryHealth) reset() {
+	h.recent = nil
+}
+
+// pauseIfUnhealthy pauses sending and alerts the operator when more than
+// rate_limiting.delivery_health.max_undelivered_percent of the last window
+// sends were still pending when checked. Unlike a few
+// pending messages in a row (ban_pending_threshold), many recipients not
+// receiving messages over a longer stretch suggests they are blocking or
+// reporting the account.
+func (c *WhatsAppClient) pauseIfUnhealthy() {
+	health := c.config.RateLimiting.DeliveryHealth
+	if health.Window <= 0 {
+		return
+	}
+	undelivered, total := c.deliveryHealth.counts()
+	if total < health.Window || float64(undelivered)*100 < health.MaxUndeliveredPercent*float64(total) {
+		return
+	}
+
+	pause := time.Duration(health.PauseMinutes) * time.Minute
+	status := fmt.Sprintf("%d of the last %d messages were not delivered (threshold %g%%). Recipients may be blocking or reporting this account.",
+		undelivered, total, health.MaxUndeliveredPercent)
+	automessage.Log("warn", "==========================================================")
+	automessage.Log("warn", fmt.Sprintf("DELIVERY HEALTH: %s", status))
+	automessage.Log("warn", fmt.Sprintf("Pausing for %v before resuming (resumes at %s)",
+		pause, time.Now().Add(pause).Format("15:04:05")))
+	automessage.Log("warn", "==========================================================")
+	NotifyAlert(c.config.Notifications, "WhatsApp Automation paused: poor delivery",
+		fmt.Sprintf("%s\nSending is paused for %v. Stop the run if this keeps happening.", status, pause))
+	time.Sleep(pause)
+
+	automessage.Log("info", "Delivery health pause finished, resuming sending")
+	c.deliveryHealth.reset()
+}
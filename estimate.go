@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// defaultSendSeconds is the browser time assumed for one send when no
+// earlier run has been measured. It is on the slow side on purpose.
+const defaultSendSeconds = 15
+
+// runStats is what a finished run measured, kept in files.run_stats_path to
+// estimate the next run
+type runStats struct {
+	Updated            time.Time `json:"updated"`
+	Contacts           int       `json:"contacts"`
+	AverageSendSeconds float64   `json:"average_send_seconds"`
+}
+
+// loadRunStats reads the stats of the previous run; ok is false when no run
+// has been measured yet
+func loadRunStats(path string) (stats runStats, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return runStats{}, false, nil
+	}
+	if err != nil {
+		return runStats{}, false, fmt.Errorf("failed to read run stats: %w", err)
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return runStats{}, false, fmt.Errorf("failed to parse run stats %s: %w", path, err)
+	}
+	return stats, stats.AverageSendSeconds > 0, nil
+}
+
+// saveRunStats records this run's average send time for the next estimate
+func saveRunStats(path string, contacts int, averages []phaseTiming) error {
+	var total time.Duration
+	for _, avg := range averages {
+		total += avg.duration
+	}
+	data, err := json.MarshalIndent(runStats{
+		Updated:            time.Now(),
+		Contacts:           contacts,
+		AverageSendSeconds: total.Seconds(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run stats: %w", err)
+	}
+	return nil
+}
+
+// estimateSendDuration returns the browser time per send to estimate a run
+// with, measured by the previous run when available, and where it came from
+func estimateSendDuration(statsPath string) (time.Duration, string) {
+	stats, ok, err := loadRunStats(statsPath)
+	if err != nil {
+		automessage.Log("warn", fmt.Sprintf("Ignoring run stats: %v", err))
+	}
+	if !ok {
+		return defaultSendSeconds * time.Second,
+			fmt.Sprintf("assuming %ds per send, no earlier run measured", defaultSendSeconds)
+	}
+	sendDuration := time.Duration(stats.AverageSendSeconds * float64(time.Second))
+	return sendDuration, fmt.Sprintf("%.1fs per send measured on %s", sendDuration.Seconds(), stats.Updated.Format("2006-01-02"))
+}
+
+// EstimateRun projects how long sending to contacts takes, with the same
+// pacing -plan uses
+func EstimateRun(config *automessage.Config, contacts []automessage.Contact, msgTemplate *automessage.MessageTemplate, sendDuration time.Duration) time.Duration {
+	start := time.Now()
+	return planEnd(BuildPlan(config, contacts, msgTemplate, start, sendDuration), start).Sub(start)
+}
+
+// formatEstimate renders a run length the way people say it, e.g. "6h20m"
+// or "45m", never less than a minute
+func formatEstimate(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		d = time.Minute
+	}
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
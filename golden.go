@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"whatsapp-automation/automessage"
+)
+
+// RunTemplateTests renders every fixture contact through the configured
+// template and compares the output with the golden files in expectedDir,
+// named by goldenName so reordering or inserting fixtures keeps each one
+// paired with its file. With update set, the golden files are (re)written
+// instead. Returns the number of mismatches.
+func RunTemplateTests(config *automessage.Config, fixturesPath, expectedDir string, update bool) (int, error) {
+	msgTemplate, err := automessage.LoadMessageTemplate(config.Files, config.Template.Variants)
+	if err != nil {
+		return 0, err
+	}
+	msgTemplate.SetExpandEmoji(config.Template.ExpandEmoji)
+	msgTemplate.SetTrimBlankLines(config.Template.TrimBlankLines)
+	if config.Files.TemplateVars != "" {
+		vars, err := automessage.LoadTemplateVars(config.Files.TemplateVars)
+		if err != nil {
+			return 0, err
+		}
+		msgTemplate.SetGlobals(vars, config.Files.TemplateVarsPrecedence == "global")
+	}
+
+	contacts, err := automessage.ParseCSV(fixturesPath, automessage.CSVOptions{
+		NameColumns:  config.Files.NameColumns,
+		PhoneColumns: config.Files.PhoneColumns,
+		RequireName:  config.Files.RequireName,
+		National:     config.Files.NationalToInternational,
+		Schema:       config.Files.Schema,
+		Transforms:   config.Files.Transforms,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to load fixtures: %w", err)
+	}
+
+	goldenPaths := make([]string, len(contacts))
+	seen := make(map[string]string, len(contacts))
+	for i, contact := range contacts {
+		name, err := goldenName(contact)
+		if err != nil {
+			return 0, err
+		}
+		if other, ok := seen[name]; ok {
+			return 0, fmt.Errorf("fixtures %s and %s both use the golden file %s; give them distinct expected values", other, contact.Name, name)
+		}
+		seen[name] = contact.Name
+		goldenPaths[i] = filepath.Join(expectedDir, name)
+	}
+
+	if update {
+		if err := os.MkdirAll(expectedDir, 0755); err != nil {
+			return 0, fmt.Errorf("failed to create expected directory: %w", err)
+		}
+	}
+
+	mismatches := 0
+	for i, contact := range contacts {
+		goldenPath := goldenPaths[i]
+
+		actual, err := msgTemplate.Render(contact)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("FAIL %s (%s): %v", goldenPath, contact.Name, err))
+			mismatches++
+			continue
+		}
+
+		if update {
+			if err := os.WriteFile(goldenPath, []byte(actual), 0644); err != nil {
+				return mismatches, fmt.Errorf("failed to write golden file: %w", err)
+			}
+			automessage.Log("info", fmt.Sprintf("UPDATED %s (%s)", goldenPath, contact.Name))
+			continue
+		}
+
+		expected, err := os.ReadFile(goldenPath)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("FAIL %s (%s): %v", goldenPath, contact.Name, err))
+			mismatches++
+			continue
+		}
+
+		if string(expected) != actual {
+			automessage.Log("error", fmt.Sprintf("FAIL %s (%s):\n%s", goldenPath, contact.Name, lineDiff(string(expected), actual)))
+			mismatches++
+			continue
+		}
+
+		automessage.Log("info", fmt.Sprintf("ok   %s (%s)", goldenPath, contact.Name))
+	}
+
+	automessage.Log("info", fmt.Sprintf("Template tests: %d fixtures, %d mismatches", len(contacts), mismatches))
+	return mismatches, nil
+}
+
+// goldenName returns the golden file name for a fixture: the value of its
+// expected column if it has one, otherwise its phone number's digits.
+// Groups have no number, so they need the expected column.
+func goldenName(contact automessage.Contact) (string, error) {
+	id := strings.TrimSpace(contact.FieldValue("expected"))
+	if id == "" {
+		id = automessage.CleanPhoneNumber(contact.PhoneNumber)
+	}
+	if id == "" {
+		return "", fmt.Errorf("fixture %s has no phone number; add an expected column naming its golden file", contact.Name)
+	}
+	if id != filepath.Base(id) || id == "." || id == ".." {
+		return "", fmt.Errorf("fixture %s: expected value %q must be a plain file name", contact.Name, id)
+	}
+	return id + ".txt", nil
+}
+
+// lineDiff returns a simple line-by-line diff, marking expected lines with
+// "-" and actual lines with "+"
+func lineDiff(expected, actual string) string {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		hasE, hasA := i < len(expectedLines), i < len(actualLines)
+		if hasE {
+			e = expectedLines[i]
+		}
+		if hasA {
+			a = actualLines[i]
+		}
+		if hasE && hasA && e == a {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  line %d:\n", i+1))
+		if hasE {
+			b.WriteString(fmt.Sprintf("  - %q\n", e))
+		}
+		if hasA {
+			b.WriteString(fmt.Sprintf("  + %q\n", a))
+		}
+	}
+	return b.String()
+}
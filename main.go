@@ -1,64 +1,379 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
+
+	"whatsapp-automation/automessage"
 )
 
 type MessageResult struct {
-	Contact Contact
-	Success bool
-	Error   error
+	Contact    automessage.Contact
+	Success    bool
+	Unverified bool // Enter was pressed but delivery could not be confirmed
+	Error      error
+	Screenshot string // Preview screenshot of the composed message, if taken
 }
 
+// ErrPartialSend marks a contact whose first message (the image with
+// image_then_text, or the first part of a split message) went out but a
+// follow-up did not
+var ErrPartialSend = errors.New("first message sent but a follow-up failed")
+
 func main() {
 	// Parse command-line flags
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	dryRun := flag.Bool("dry-run", false, "Perform a dry run without sending messages")
+	startAt := flag.String("start-at", "", "Wait until this time (RFC3339, e.g. 2025-01-02T09:00:00+02:00) before sending")
+	streamResults := flag.Bool("stream-results", false, "Write each send result as NDJSON to stdout (logs go to stderr)")
+	reportHTML := flag.String("report-html", "", "Write an HTML report with preview screenshots to this path")
+	dumpContacts := flag.String("dump-contacts", "", "Write the final list of contacts to be messaged to this CSV")
+	printURLs := flag.Bool("print-urls", false, "Print the chat URL and rendered message for each contact without a browser, then exit")
+	dumpOnly := flag.Bool("dump-only", false, "Exit after writing -dump-contacts without sending")
+	testTemplates := flag.String("test-templates", "", "Render fixture contacts from this CSV and compare with golden files, then exit")
+	expectedDir := flag.String("expected-dir", "testdata/expected", "Directory of golden files for -test-templates")
+	updateGolden := flag.Bool("update-golden", false, "Rewrite the golden files from the current template (with -test-templates)")
+	adminAddr := flag.String("admin-addr", "", "Serve the admin API (/status, /pause, /resume, /stop) on this address, e.g. :8080")
+	failFast := flag.Bool("fail-fast", false, "Stop the run at the first failed or unverified send")
+	noTrack := flag.Bool("no-track", false, "Disable completed/unverified tracking so every contact is sent on every run (testing only)")
+	profile := flag.String("profile", "", "Use the named browser session under browser.profiles_base_dir")
+	listProfiles := flag.Bool("list-profiles", false, "List available browser profiles and exit")
+	preflightSend := flag.Bool("preflight-send", false, "Send a test message to your own number before the run and abort if it isn't accepted")
+	renderOnly := flag.String("render-only", "", "Write each contact's rendered message and resolved image to this directory for manual sending, then exit (no browser)")
+	stream := flag.Bool("stream", false, "Read contacts from the CSV while sending instead of loading the whole list first (for very large files)")
+	plan := flag.Bool("plan", false, "Print the projected send time of each remaining contact without sending, then exit (no browser)")
+	planFormat := flag.String("plan-format", "table", "Output format for -plan: table or csv")
+	planSendSeconds := flag.Float64("plan-send-seconds", defaultSendSeconds, "Assumed browser time per send for -plan; see \"Average time per send attempt\" in a previous run's summary")
+	exportCompleted := flag.String("export-completed", "", "Write the input contacts recorded in the completed tracker to this CSV, then exit (no browser)")
+	resendChanged := flag.Bool("resend-changed", false, "Report contacts as new, changed since their last send or unchanged; changed ones are sent again (requires tracker.key_strategy: content)")
+	remaining := flag.Bool("remaining", false, "Print how many contacts are still to be messaged (after completed, unverified and excluded ones), then exit")
+	assumeYes := flag.Bool("yes", false, "Start runs projected over rate_limiting.confirm_over_hours, or with a suspicious completed file, without asking")
+	interactive := flag.Bool("interactive", false, "Pause before each contact's send so the operator can send, skip or edit the composed message in Chrome")
+	browserConsole := flag.Bool("browser-console", false, "Forward browser console output to the log (requires debug log level)")
+	var overrides automessage.ConfigOverrides
+	flag.Var(&overrides, "set", "Override a config value for this run, e.g. -set browser.headless=true (repeatable)")
 	flag.Parse()
 
+	var startTimeAt time.Time
+	if *startAt != "" {
+		var err error
+		startTimeAt, err = time.Parse(time.RFC3339, *startAt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -start-at time %q: %v\n", *startAt, err)
+			os.Exit(1)
+		}
+	}
+
+	// Keep stdout pure NDJSON when streaming results
+	var streamer *ResultStreamer
+	if *streamResults {
+		automessage.SetConsoleOutput(os.Stderr)
+		streamer = NewResultStreamer(os.Stdout)
+	}
+	if *printURLs || *plan || *remaining {
+		automessage.SetConsoleOutput(os.Stderr)
+	}
+	if *plan && *planFormat != "table" && *planFormat != "csv" {
+		fmt.Fprintf(os.Stderr, "Invalid -plan-format %q: must be table or csv\n", *planFormat)
+		os.Exit(1)
+	}
+
 	// Load configuration
-	Log("info", fmt.Sprintf("Loading configuration from %s", *configPath))
-	config, err := LoadConfig(*configPath)
+	automessage.Log("info", fmt.Sprintf("Loading configuration from %s", *configPath))
+	config, err := automessage.LoadConfig(*configPath, overrides)
 	if err != nil {
-		Log("error", fmt.Sprintf("Failed to load config: %v", err))
+		automessage.Log("error", fmt.Sprintf("Failed to load config: %v", err))
+		os.Exit(1)
+	}
+	for _, override := range overrides {
+		automessage.Log("info", fmt.Sprintf("Config override: %s", override))
+	}
+	if *browserConsole {
+		config.Browser.ConsoleLog = true
+	}
+	if *resendChanged && config.Tracker.KeyStrategy != automessage.TrackerKeyContent {
+		automessage.Log("error", fmt.Sprintf("-resend-changed needs tracker.key_strategy: content, with %s a changed contact is still completed", config.Tracker.KeyStrategy))
+		os.Exit(1)
+	}
+	if *dumpOnly && *dumpContacts == "" {
+		automessage.Log("error", "-dump-only needs -dump-contacts, there is nothing to write before exiting")
 		os.Exit(1)
 	}
+	if *resendChanged && *noTrack {
+		automessage.Log("error", "-resend-changed can't be used with -no-track, which sends every contact")
+		os.Exit(1)
+	}
+	if config.Sandbox() {
+		automessage.Log("warn", "Environment: sandbox - chats are opened and messages composed, but nothing is sent")
+	}
+
+	if *listProfiles {
+		profiles, err := automessage.ListProfiles(config.Browser.ProfilesBaseDir)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("Failed to list profiles: %v", err))
+			os.Exit(1)
+		}
+		if len(profiles) == 0 {
+			fmt.Printf("No profiles found in %s\n", config.Browser.ProfilesBaseDir)
+		}
+		for _, name := range profiles {
+			fmt.Println(name)
+		}
+		os.Exit(0)
+	}
+
+	if *profile != "" {
+		if err := config.UseProfile(*profile); err != nil {
+			automessage.Log("error", fmt.Sprintf("Failed to select profile: %v", err))
+			os.Exit(1)
+		}
+		automessage.Log("info", fmt.Sprintf("Using browser profile '%s' (%s)", *profile, config.Browser.UserDataDir))
+	}
+
+	if config.Files.OutputDir != "" {
+		if err := os.MkdirAll(config.Files.OutputDir, 0755); err != nil {
+			automessage.Log("error", fmt.Sprintf("Failed to create output directory: %v", err))
+			os.Exit(1)
+		}
+		*reportHTML = config.OutputPath(*reportHTML)
+		*dumpContacts = config.OutputPath(*dumpContacts)
+		*exportCompleted = config.OutputPath(*exportCompleted)
+	}
 
 	// Initialize logger
-	if err := InitLogger(config); err != nil {
+	if err := automessage.InitLogger(config); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 		os.Exit(1)
 	}
-	defer CloseLogger()
+	defer automessage.CloseLogger()
 
-	Log("info", "WhatsApp Automation started")
+	// Snapshot-test template rendering without any browser
+	if *testTemplates != "" {
+		mismatches, err := RunTemplateTests(config, *testTemplates, *expectedDir, *updateGolden)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("Template tests failed: %v", err))
+			os.Exit(1)
+		}
+		if mismatches > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Load contacts from CSV
-	Log("info", fmt.Sprintf("Loading contacts from %s", config.Files.CSVPath))
-	contacts, err := ParseCSV(config.Files.CSVPath)
-	if err != nil {
-		Log("error", fmt.Sprintf("Failed to parse CSV: %v", err))
-		os.Exit(1)
+	// Count who is left without loading anything else
+	if *remaining {
+		plan, err := automessage.PlanRun(config)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("Failed to plan run: %v", err))
+			os.Exit(1)
+		}
+		fmt.Printf("Loaded: %d\nCompleted: %d\nUnverified: %d\nExcluded: %d\nRemaining: %d\n",
+			plan.Loaded, plan.Completed, plan.Unverified, plan.Excluded, len(plan.Remaining))
+		if *resendChanged {
+			fmt.Printf("New: %d\nChanged: %d\n", len(plan.Remaining)-plan.Changed, plan.Changed)
+		}
+		return
+	}
+
+	// Everyone already messaged, back in the shape of the input list
+	if *exportCompleted != "" {
+		exported, unmatched, err := ExportCompleted(config, *exportCompleted)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("Failed to export completed contacts: %v", err))
+			os.Exit(1)
+		}
+		automessage.Log("info", fmt.Sprintf("Exported %d completed contacts to %s", exported, *exportCompleted))
+		if unmatched > 0 {
+			automessage.Log("warn", fmt.Sprintf("%d completed entries match no row of %s and were left out", unmatched, config.Files.CSVPath))
+		}
+		return
 	}
-	Log("info", fmt.Sprintf("Loaded %d contacts", len(contacts)))
 
-	// Load message template
-	Log("info", fmt.Sprintf("Loading message template from %s", config.Files.TemplatePath))
-	msgTemplate, err := LoadTemplate(config.Files.TemplatePath)
+	automessage.Log("info", "WhatsApp Automation started")
+
+	// Load contacts from CSV, or with -stream only open it and read contacts
+	// one at a time as they are sent
+	csvOpts := automessage.CSVOptionsFor(config)
+	var contacts []automessage.Contact
+	var contactReader *automessage.ContactReader
+	if *stream {
+		if *dumpContacts != "" || *printURLs || *renderOnly != "" || *plan {
+			abortRun(config, "-dump-contacts, -print-urls, -render-only and -plan need the full contact list and can't be used with -stream")
+		}
+		if automessage.IsVCardPath(config.Files.CSVPath) {
+			abortRun(config, "-stream only supports CSV contact lists, not vCard files")
+		}
+		automessage.Log("info", fmt.Sprintf("Streaming contacts from %s", config.Files.CSVPath))
+		contactReader, err = automessage.OpenContactReader(config.Files.CSVPath, csvOpts)
+		if err != nil {
+			abortRun(config, fmt.Sprintf("Failed to parse CSV: %v", err))
+		}
+		defer contactReader.Close()
+	} else {
+		automessage.Log("info", fmt.Sprintf("Loading contacts from %s", config.Files.CSVPath))
+		contacts, err = automessage.LoadContacts(config.Files.CSVPath, csvOpts)
+		if err != nil {
+			abortRun(config, fmt.Sprintf("Failed to load contacts: %v", err))
+		}
+		automessage.Log("info", fmt.Sprintf("Loaded %d contacts", len(contacts)))
+	}
+
+	// Load the message template and the optional opener, caption and image
+	// path templates
+	templates, err := automessage.LoadRunTemplates(config)
 	if err != nil {
-		Log("error", fmt.Sprintf("Failed to load template: %v", err))
-		os.Exit(1)
+		abortRun(config, fmt.Sprintf("Failed to set up templates: %v", err))
+	}
+	msgTemplate := templates.Message
+
+	// Initialize completed contacts and sent-but-unverified trackers
+	var tracker, unverifiedTracker automessage.Tracker = automessage.NullTracker{}, automessage.NullTracker{}
+	if *noTrack {
+		automessage.Log("warn", "************************************************************")
+		automessage.Log("warn", "COMPLETED TRACKING IS DISABLED (-no-track): every contact will")
+		automessage.Log("warn", "be messaged on every run and nothing is recorded. Never use this")
+		automessage.Log("warn", "with real contacts.")
+		automessage.Log("warn", "************************************************************")
+	} else {
+		automessage.Log("info", fmt.Sprintf("Loading completed contacts from %s", config.Files.CompletedCSVPath))
+		completedTracker, err := automessage.NewCompletedTracker(config.Files.CompletedCSVPath, msgTemplate, config.Tracker.KeyStrategy)
+		if err != nil {
+			abortRun(config, fmt.Sprintf("Failed to initialize completed tracker: %v", err))
+		}
+		tracker = completedTracker
+
+		automessage.Log("info", fmt.Sprintf("Loading unverified contacts from %s", config.Files.UnverifiedCSVPath))
+		unverifiedCompletedTracker, err := automessage.NewCompletedTracker(config.Files.UnverifiedCSVPath, msgTemplate, config.Tracker.KeyStrategy)
+		if err != nil {
+			abortRun(config, fmt.Sprintf("Failed to initialize unverified tracker: %v", err))
+		}
+		unverifiedTracker = unverifiedCompletedTracker
 	}
+	defer tracker.Close()
+	defer unverifiedTracker.Close()
 
-	// Initialize completed contacts tracker
-	Log("info", fmt.Sprintf("Loading completed contacts from %s", config.Files.CompletedCSVPath))
-	tracker, err := NewCompletedTracker(config.Files.CompletedCSVPath, msgTemplate.Content)
+	// Contacts that are also in another list are left out of this run
+	excludedPhones, err := automessage.LoadExcludedPhones(config)
 	if err != nil {
-		Log("error", fmt.Sprintf("Failed to initialize completed tracker: %v", err))
-		os.Exit(1)
+		abortRun(config, fmt.Sprintf("Failed to load exclude CSV: %v", err))
+	}
+
+	// Work out exactly who this run will target, after all skips
+	runPlan := automessage.FilterContacts(contacts, tracker, unverifiedTracker, excludedPhones, config.Retry.ResendUnverified)
+	targeted := runPlan.Remaining
+	if contactReader == nil {
+		automessage.Log("info", fmt.Sprintf("%d of %d contacts will be skipped as already completed", runPlan.Completed, runPlan.Loaded))
+		if *resendChanged {
+			automessage.Log("info", fmt.Sprintf("Resend changed: %d new, %d changed since last send, %d unchanged (skipped)",
+				len(runPlan.Remaining)-runPlan.Changed, runPlan.Changed, runPlan.Completed))
+		}
+	} else if config.Tracker.GuardCompletedPercent >= 0 && !*noTrack {
+		// The guard compares the whole list against the completed file,
+		// which a streamed list only reveals while it is being sent
+		automessage.Log("warn", "tracker.guard_completed_percent can't check a streamed list before sending; completed contacts are counted as they are read, check them against files.completed_csv_path")
+	}
+
+	if *dumpContacts != "" {
+		if err := WriteContactsCSV(*dumpContacts, targeted); err != nil {
+			abortRun(config, fmt.Sprintf("Failed to dump contacts: %v", err))
+		}
+		automessage.Log("info", fmt.Sprintf("Wrote %d targeted contacts to %s", len(targeted), *dumpContacts))
+	}
+	if *renderOnly != "" {
+		invalid, err := RenderPackage(*renderOnly, config, targeted, templates)
+		if err != nil {
+			abortRun(config, fmt.Sprintf("Failed to render messages: %v", err))
+		}
+		automessage.Log("info", fmt.Sprintf("Rendered messages for %d contacts to %s (%d could not be rendered)", len(targeted)-invalid, *renderOnly, invalid))
+		if invalid > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *printURLs {
+		invalid := PrintChatURLs(os.Stdout, config.Browser.SendURLBase, targeted, msgTemplate)
+		automessage.Log("info", fmt.Sprintf("Printed chat URLs for %d contacts (%d invalid)", len(targeted), invalid))
+		if invalid > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *plan {
+		planStart := time.Now()
+		if !startTimeAt.IsZero() {
+			planStart = startTimeAt
+		}
+		sendDuration := time.Duration(*planSendSeconds * float64(time.Second))
+		entries := BuildPlan(config, targeted, msgTemplate, planStart, sendDuration)
+		if err := WritePlan(os.Stdout, entries, planStart, *planFormat); err != nil {
+			abortRun(config, fmt.Sprintf("Failed to write plan: %v", err))
+		}
+
+		end := planEnd(entries, planStart)
+		automessage.Log("info", fmt.Sprintf("Plan: %d contacts starting %s, projected to finish %s (%v)",
+			len(targeted), planStart.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"), end.Sub(planStart).Round(time.Second)))
+		if end.YearDay() != planStart.YearDay() || end.Year() != planStart.Year() {
+			automessage.Log("warn", "The list can't be completed on the start day at this pace - contacts with day > 0 would be sent after midnight")
+		}
+		return
+	}
+	if *dumpOnly {
+		automessage.Log("info", "Exiting after contact dump (-dump-only)")
+		return
+	}
+
+	// A completed file from another campaign silently skips the whole list
+	if reason := automessage.SuspiciousCompleted(config, runPlan); reason != "" && !*noTrack {
+		automessage.Log("warn", "************************************************************")
+		automessage.Log("warn", "THE COMPLETED FILE MAY NOT BELONG TO THIS CONTACT LIST")
+		automessage.Log("warn", reason)
+		automessage.Log("warn", "Check files.completed_csv_path before continuing.")
+		automessage.Log("warn", "************************************************************")
+		if !*dryRun && !*assumeYes {
+			if !stdinIsTerminal() {
+				abortRun(config, "Completed file looks wrong for this list (tracker.guard_completed_percent); pass -yes to run anyway")
+			}
+			if !promptYesNo("Skip these contacts as already completed and continue?", false) {
+				automessage.Log("info", "Run not confirmed, nothing was sent")
+				return
+			}
+		}
+	}
+
+	// Estimate how long the run takes, so an all-night run is no surprise.
+	// With -stream the list isn't known yet.
+	if contactReader == nil && len(targeted) > 0 {
+		sendDuration, source := estimateSendDuration(config.Files.RunStatsPath)
+		estimate := EstimateRun(config, targeted, msgTemplate, sendDuration)
+		automessage.Log("info", fmt.Sprintf("This run will take ~%s for %d contacts (%s)", formatEstimate(estimate), len(targeted), source))
+
+		hours := estimate.Hours()
+		if warnOver := config.RateLimiting.WarnOverHours; warnOver > 0 && hours > warnOver {
+			automessage.Log("warn", fmt.Sprintf("The run is projected to take longer than rate_limiting.warn_over_hours (%gh); see -plan for the timeline", warnOver))
+		}
+		if confirmOver := config.RateLimiting.ConfirmOverHours; confirmOver > 0 && hours > confirmOver && !*dryRun && !*assumeYes {
+			if !stdinIsTerminal() {
+				abortRun(config, fmt.Sprintf("Run is projected to take ~%s, over rate_limiting.confirm_over_hours (%gh); pass -yes to start it without a terminal", formatEstimate(estimate), confirmOver))
+			}
+			if !promptYesNo(fmt.Sprintf("This run will take ~%s for %d contacts. Start it?", formatEstimate(estimate), len(targeted)), false) {
+				automessage.Log("info", "Run not confirmed, nothing was sent")
+				return
+			}
+		}
+	}
+
+	// -interactive needs someone at the terminal for every contact
+	if *interactive {
+		if *dryRun {
+			automessage.Log("warn", "-interactive has no effect with -dry-run, nothing is composed in the browser")
+		} else if !stdinIsTerminal() {
+			abortRun(config, "-interactive needs a terminal to ask before each send")
+		}
 	}
 
 	// Initialize WhatsApp client
@@ -67,37 +382,243 @@ func main() {
 	// Initialize browser automation (skip for dry-run)
 	if !*dryRun {
 		if err := whatsappClient.Initialize(); err != nil {
-			Log("error", fmt.Sprintf("Failed to initialize WhatsApp client: %v", err))
-			os.Exit(1)
+			abortRun(config, fmt.Sprintf("Failed to initialize WhatsApp client: %v", err))
 		}
 		defer whatsappClient.Close()
 	}
 
+	// Remote control for long unattended runs
+	var admin *AdminServer
+	if *adminAddr != "" {
+		admin, err = StartAdminServer(*adminAddr, config.Admin.Token)
+		if err != nil {
+			abortRun(config, fmt.Sprintf("Failed to start admin API: %v", err))
+		}
+	}
+
+	// Wait for the scheduled start, with the browser session already logged in
+	if !startTimeAt.IsZero() {
+		waitUntil(startTimeAt)
+	}
+
+	// Prove the session can send before any contact is messaged
+	if *preflightSend {
+		switch {
+		case *dryRun:
+			automessage.Log("info", "Skipping preflight send in dry-run mode")
+		case config.Sandbox():
+			automessage.Log("warn", "Skipping preflight send in the sandbox environment, which never sends")
+		default:
+			if err := whatsappClient.PreflightSend(config.Notifications.SelfPhone); err != nil {
+				abortRun(config, fmt.Sprintf("Preflight check failed, not sending to any contact: %v", err))
+			}
+		}
+	}
+
+	// Send the campaign to a canary number first and only go on if it worked
+	if config.Files.CanaryPhone != "" {
+		if *dryRun {
+			canary := canaryContact(config.Files)
+			if send, err := composeContactSend(canary, config, templates); err != nil {
+				abortRun(config, fmt.Sprintf("Failed to compose canary message: %v", err))
+			} else {
+				automessage.Log("info", fmt.Sprintf("[DRY RUN] Would send canary message to %s first:\n%s", canary.PhoneNumber, send.Message))
+			}
+		} else if err := RunCanary(config, whatsappClient, templates); err != nil {
+			abortRun(config, fmt.Sprintf("Canary check failed, not sending to the list: %v", err))
+		}
+	}
+
 	// Process contacts
 	results := make([]MessageResult, 0, len(contacts))
+	recordResult := func(result MessageResult) {
+		results = append(results, result)
+		if streamer != nil {
+			if err := streamer.Write(result); err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to stream result for %s: %v", result.Contact.PhoneNumber, err))
+			}
+		}
+	}
 	successCount := 0
 	failureCount := 0
 	skippedCount := 0
+	unverifiedCount := 0
+	skippedUnverifiedCount := 0
+	excludedCount := 0
+	textOnlyCount := 0
+	attachmentsSent := make(map[AttachmentKind]int)
+	changedContacts := make(map[string]bool) // -resend-changed: clean phones sent before with other content
+	partialCount := 0
+	alreadyInChatCount := 0
+	operatorSkippedCount := 0
+
+	// Sends happen in bursts of batch_size with a cooldown in between
+	batchSize := config.RateLimiting.BatchSize
+	batchCooldown := time.Duration(config.RateLimiting.BatchCooldownMinutes * float64(time.Minute))
+	batchNumber := 1
+	sentInBatch := 0
 
 	startTime := time.Now()
 
-	for i, contact := range contacts {
-		Log("info", fmt.Sprintf("Processing contact %d/%d: %s (%s)",
-			i+1, len(contacts), contact.Name, contact.PhoneNumber))
+	// With -fail-fast the first failure stops the run, and the admin API
+	// can stop it too; contacts after that are left untouched for the next run
+	stopReason := ""
+	failedFast := false
+	notProcessedCount := 0
+
+	// Periodic progress with an ETA from recent per-contact times
+	progress := &ProgressEstimator{}
+	contactStart := time.Now()
+
+	// Contacts come from the loaded list, or straight from the CSV with
+	// -stream, where the total is only known once the file has been read and
+	// progress is reported without one
+	total := len(contacts)
+	nextContact := func(i int) (automessage.Contact, bool, error) {
+		if i >= len(contacts) {
+			return automessage.Contact{}, false, nil
+		}
+		return contacts[i], true, nil
+	}
+	// remainingAfter counts the contacts not yet handed out after the i-th
+	remainingAfter := func(i int) int {
+		return len(contacts) - i - 1
+	}
+	if contactReader != nil {
+		nextContact = func(int) (automessage.Contact, bool, error) {
+			contact, err := contactReader.Next()
+			if err == io.EOF {
+				return automessage.Contact{}, false, nil
+			}
+			if err != nil {
+				return automessage.Contact{}, false, err
+			}
+			total++
+			return contact, true, nil
+		}
+		remainingAfter = func(int) int {
+			remaining := 0
+			for {
+				if _, err := contactReader.Next(); err != nil {
+					break
+				}
+				remaining++
+			}
+			total += remaining
+			return remaining
+		}
+	}
+	var readErr error
+
+	for i := 0; ; i++ {
+		contact, ok, err := nextContact(i)
+		if err != nil {
+			readErr = err
+			stopReason = fmt.Sprintf("failed to read contacts: %v", err)
+			automessage.Log("error", fmt.Sprintf("Run stopped - %s", stopReason))
+			break
+		}
+		if !ok {
+			break
+		}
+
+		if i > 0 {
+			progress.Add(time.Since(contactStart))
+			if every := config.Logging.ProgressEvery; every > 0 && i%every == 0 {
+				automessage.Log("info", formatProgress(i, len(contacts), successCount, failureCount+partialCount,
+					skippedCount+skippedUnverifiedCount+alreadyInChatCount+operatorSkippedCount, unverifiedCount, progress))
+			}
+		}
+		contactStart = time.Now()
+
+		if *failFast && failureCount+unverifiedCount+partialCount > 0 {
+			notProcessedCount = remainingAfter(i) + 1
+			last := results[len(results)-1]
+			stopReason = fmt.Sprintf("fail-fast stopped after failure for %s (%s): %v",
+				last.Contact.Name, last.Contact.PhoneNumber, last.Error)
+			failedFast = true
+			automessage.Log("error", fmt.Sprintf("%s - %d contacts not processed", stopReason, notProcessedCount))
+			break
+		}
+
+		if admin != nil && !admin.Checkpoint(AdminStatus{
+			Total:      len(contacts), // 0 (unknown) when streaming
+			Processed:  i,
+			Successful: successCount,
+			Failed:     failureCount + partialCount,
+			Skipped:    skippedCount + skippedUnverifiedCount + alreadyInChatCount + operatorSkippedCount,
+			Unverified: unverifiedCount,
+			Current:    fmt.Sprintf("%s (%s)", contact.Name, contact.ChatLabel()),
+		}) {
+			notProcessedCount = remainingAfter(i) + 1
+			stopReason = "stopped via admin API"
+			automessage.Log("warn", fmt.Sprintf("Run %s - %d contacts not processed", stopReason, notProcessedCount))
+			break
+		}
+
+		if contactReader != nil {
+			automessage.Log("info", fmt.Sprintf("Processing contact %d: %s (%s)",
+				i+1, contact.Name, contact.ChatLabel()))
+		} else {
+			automessage.Log("info", fmt.Sprintf("Processing contact %d/%d: %s (%s)",
+				i+1, len(contacts), contact.Name, contact.ChatLabel()))
+		}
+
+		// Check if the contact is in the exclude list
+		if automessage.IsExcluded(excludedPhones, contact) {
+			automessage.Log("info", fmt.Sprintf("Skipping %s - excluded (in %s)", contact.ChatLabel(), config.Files.ExcludeCSV))
+			excludedCount++
+			continue
+		}
 
 		// Check if already completed
 		if tracker.IsCompleted(contact) {
-			Log("info", fmt.Sprintf("Skipping %s - already sent message previously", contact.PhoneNumber))
 			skippedCount++
+			if contactReader != nil {
+				automessage.Log("info", fmt.Sprintf("Skipping %s - already sent message previously (%d of %d streamed contacts skipped as already completed so far)",
+					contact.ChatLabel(), skippedCount, i+1))
+			} else {
+				automessage.Log("info", fmt.Sprintf("Skipping %s - already sent message previously", contact.ChatLabel()))
+			}
+			continue
+		}
+
+		// Check if a previous run may already have delivered this message
+		if !config.Retry.ResendUnverified && unverifiedTracker.IsCompleted(contact) {
+			automessage.Log("warn", fmt.Sprintf("Skipping %s - previous send could not be verified (set retry.resend_unverified to resend)", contact.ChatLabel()))
+			skippedUnverifiedCount++
 			continue
 		}
 
-		// Render message for this contact
-		message, err := msgTemplate.Render(contact)
+		if *resendChanged && tracker.SentBefore(contact) {
+			automessage.Log("info", fmt.Sprintf("%s changed since the last send, sending again", contact.ChatLabel()))
+			changedContacts[automessage.CleanPhoneNumber(contact.PhoneNumber)] = true
+		}
+
+		// Dry run lints the dataset offline, starting with the phone format
+		// (unless chats are found by searching for something else)
+		if *dryRun && contact.Type != automessage.ContactGroup && (config.Browser.OpenChatBy != "search" || config.Browser.SearchField == "phone") {
+			if err := automessage.ValidatePhoneNumber(contact.PhoneNumber); err != nil {
+				automessage.Log("error", fmt.Sprintf("[DRY RUN] Invalid phone number for %s: %v", contact.Name, err))
+				recordResult(MessageResult{
+					Contact: contact,
+					Success: false,
+					Error:   fmt.Errorf("%w: %v", ErrInvalidFormat, err),
+				})
+				failureCount++
+				continue
+			}
+		}
+
+		if variant := msgTemplate.VariantFor(contact); variant != "" {
+			automessage.Log("debug", fmt.Sprintf("%s gets template variant %s", contact.ChatLabel(), variant))
+		}
+
+		// Render the message, opener and captions and pick the attachment
+		send, err := composeContactSend(contact, config, templates)
 		if err != nil {
-			Log("error", fmt.Sprintf("Failed to render template for %s: %v",
-				contact.Name, err))
-			results = append(results, MessageResult{
+			automessage.Log("error", fmt.Sprintf("Failed to compose message for %s: %v", contact.Name, err))
+			recordResult(MessageResult{
 				Contact: contact,
 				Success: false,
 				Error:   err,
@@ -105,11 +626,103 @@ func main() {
 			failureCount++
 			continue
 		}
+		if send.TextOnlyReason != "" {
+			automessage.Log(send.TextOnlyLevel, fmt.Sprintf("Sending text only to %s: %s", contact.ChatLabel(), send.TextOnlyReason))
+			textOnlyCount++
+		}
+		parts, firstMessage, followUps, carousel := send.Parts, send.First, send.FollowUps, send.Carousel
+
+		sendOpts := send.Opts
+		sendOpts.MaxRetries = contact.MaxRetries
+		sendOpts.SkipIfInChat = config.Browser.SkipIfAlreadyInChat
+		sendOpts.Recipient = contact.Name
+		sendOpts.Review = *interactive
+
+		priorityName, priority, err := automessage.ContactPriority(config, contact)
+		if err != nil {
+			automessage.Log("error", fmt.Sprintf("Unknown priority %q for %s", priorityName, contact.Name))
+			recordResult(MessageResult{
+				Contact: contact,
+				Success: false,
+				Error:   err,
+			})
+			failureCount++
+			continue
+		}
+		sendOpts.ExtraDelay = time.Duration(priority.ExtraDelaySeconds * float64(time.Second))
+		sendOpts.SuccessCriteria = priority.SuccessCriteria
+
+		// Groups have no number, their chat is always found by name
+		if contact.Type == automessage.ContactGroup {
+			sendOpts.Group = true
+			sendOpts.SearchTerm = contact.Name
+		} else if config.Browser.OpenChatBy == "search" {
+			sendOpts.SearchTerm = contact.FieldValue(config.Browser.SearchField)
+			if sendOpts.SearchTerm == "" {
+				automessage.Log("error", fmt.Sprintf("No %s to search for %s", config.Browser.SearchField, contact.Name))
+				recordResult(MessageResult{
+					Contact: contact,
+					Success: false,
+					Error:   fmt.Errorf("empty search term (browser.search_field %q)", config.Browser.SearchField),
+				})
+				failureCount++
+				continue
+			}
+		}
 
 		if *dryRun {
-			Log("info", fmt.Sprintf("[DRY RUN] Would send message to %s:\n%s",
-				contact.PhoneNumber, message))
-			results = append(results, MessageResult{
+			if len(carousel) > 0 {
+				for i, image := range carousel {
+					if strings.TrimSpace(image.Caption) == "" {
+						automessage.Log("info", fmt.Sprintf("[DRY RUN] Would send image %d/%d (%s) to %s without a caption",
+							i+1, len(carousel), image.Path, contact.ChatLabel()))
+						continue
+					}
+					automessage.Log("info", fmt.Sprintf("[DRY RUN] Would send image %d/%d (%s) to %s with caption:\n%s",
+						i+1, len(carousel), image.Path, contact.ChatLabel(), image.Caption))
+				}
+				for i, part := range parts {
+					automessage.Log("info", fmt.Sprintf("[DRY RUN] followed by message %d/%d:\n%s", len(carousel)+i+1, len(carousel)+len(parts), part))
+				}
+				recordResult(MessageResult{
+					Contact: contact,
+					Success: true,
+					Error:   nil,
+				})
+				successCount++
+				continue
+			}
+			if len(followUps) > 0 {
+				kind := "message"
+				if send.SeparateCaption {
+					kind = "image with caption"
+				}
+				automessage.Log("info", fmt.Sprintf("[DRY RUN] Would send %s to %s:\n%s", kind, contact.ChatLabel(), firstMessage))
+				for i, followUp := range followUps {
+					automessage.Log("info", fmt.Sprintf("[DRY RUN] followed by message %d/%d:\n%s", i+2, len(followUps)+1, followUp))
+				}
+				recordResult(MessageResult{
+					Contact: contact,
+					Success: true,
+					Error:   nil,
+				})
+				successCount++
+				continue
+			}
+			if sendOpts.Attachment != "" {
+				automessage.Log("info", fmt.Sprintf("[DRY RUN] Would attach %s %s for %s", sendOpts.Attachment, sendOpts.ImagePath, contact.ChatLabel()))
+			}
+			if sendOpts.Opener != "" {
+				automessage.Log("info", fmt.Sprintf("[DRY RUN] Would send opener to %s if the chat is new:\n%s",
+					contact.ChatLabel(), sendOpts.Opener))
+			}
+			kind := "message"
+			if send.SeparateCaption {
+				kind = "image with caption"
+			}
+			automessage.Log("info", fmt.Sprintf("[DRY RUN] Would send %s to %s:\n%s",
+				kind, contact.ChatLabel(), firstMessage))
+			recordResult(MessageResult{
 				Contact: contact,
 				Success: true,
 				Error:   nil,
@@ -118,29 +731,121 @@ func main() {
 			continue
 		}
 
-		// Send message
-		err = whatsappClient.SendMessage(contact.PhoneNumber, message)
-		if err != nil {
-			Log("error", fmt.Sprintf("Failed to send message to %s: %v",
+		if batchSize > 0 {
+			if sentInBatch == batchSize {
+				automessage.Log("info", fmt.Sprintf("Batch %d complete (%d messages), cooling down for %v until %s",
+					batchNumber, sentInBatch, batchCooldown, time.Now().Add(batchCooldown).Format("15:04:05")))
+				time.Sleep(batchCooldown)
+				batchNumber++
+				sentInBatch = 0
+			}
+			if sentInBatch == 0 {
+				automessage.Log("info", fmt.Sprintf("Starting batch %d (up to %d messages)", batchNumber, batchSize))
+			}
+			sentInBatch++
+		}
+
+		// Send message, or the carousel with every part of the message
+		// following it
+		sentFirst := 1
+		if len(carousel) > 0 {
+			err = sendCarousel(whatsappClient, contact.PhoneNumber, carousel, sendOpts)
+			sentFirst, followUps = len(carousel), parts
+		} else {
+			err = whatsappClient.SendMessage(contact.PhoneNumber, firstMessage, sendOpts)
+		}
+		screenshot := whatsappClient.LastPreviewScreenshot()
+		attachmentSent := err == nil && len(carousel) == 0 && whatsappClient.LastSendHadAttachment()
+		followUpOpts := SendOptions{
+			SearchTerm:      sendOpts.SearchTerm,
+			SuccessCriteria: sendOpts.SuccessCriteria,
+			MaxRetries:      sendOpts.MaxRetries,
+			SkipIfInChat:    sendOpts.SkipIfInChat,
+			Group:           sendOpts.Group,
+			Recipient:       sendOpts.Recipient,
+		}
+		for i := 0; err == nil && i < len(followUps); i++ {
+			if followErr := whatsappClient.SendFollowUp(contact.PhoneNumber, followUps[i], followUpOpts); followErr != nil {
+				if errors.Is(followErr, ErrAlreadyInChat) {
+					continue // This part went out in an earlier run
+				}
+				if errors.Is(followErr, ErrSendUnverified) {
+					err = followErr
+				} else {
+					err = fmt.Errorf("%w: message %d/%d: %v", ErrPartialSend, sentFirst+i+1, sentFirst+len(followUps), followErr)
+				}
+			}
+		}
+		if errors.Is(err, ErrSkippedByOperator) {
+			// Not marked completed, the next run offers the contact again
+			automessage.Log("info", fmt.Sprintf("Skipped %s at the operator's request", contact.ChatLabel()))
+			operatorSkippedCount++
+		} else if errors.Is(err, ErrAlreadyInChat) {
+			automessage.Log("info", fmt.Sprintf("Skipping %s - message is already in the chat", contact.ChatLabel()))
+
+			// Record it so later runs skip the contact without opening the chat
+			if err := tracker.MarkCompleted(contact); err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to mark %s as completed: %v", contact.PhoneNumber, err))
+			}
+			alreadyInChatCount++
+		} else if errors.Is(err, ErrPartialSend) {
+			automessage.Log("error", fmt.Sprintf("First message sent to %s but a follow-up failed: %v", contact.Name, err))
+			recordResult(MessageResult{
+				Contact:    contact,
+				Success:    false,
+				Error:      err,
+				Screenshot: screenshot,
+			})
+			partialCount++
+		} else if errors.Is(err, ErrSendUnverified) {
+			automessage.Log("warn", fmt.Sprintf("Message to %s may have been sent but could not be verified", contact.Name))
+
+			// Record separately so a re-run doesn't double-message this contact
+			if err := unverifiedTracker.MarkCompleted(contact); err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to mark %s as unverified: %v", contact.PhoneNumber, err))
+			}
+
+			recordResult(MessageResult{
+				Contact:    contact,
+				Unverified: true,
+				Error:      err,
+				Screenshot: screenshot,
+			})
+			unverifiedCount++
+		} else if err != nil {
+			automessage.Log("error", fmt.Sprintf("Failed to send message to %s: %v",
 				contact.Name, err))
-			results = append(results, MessageResult{
+			recordResult(MessageResult{
 				Contact: contact,
 				Success: false,
 				Error:   err,
 			})
 			failureCount++
 		} else {
-			Log("info", fmt.Sprintf("Successfully sent message to %s", contact.Name))
+			if config.Sandbox() {
+				// Nothing was sent, so nothing is marked completed
+				automessage.Log("info", fmt.Sprintf("[SANDBOX] Composed message for %s without sending", contact.Name))
+			} else {
+				automessage.Log("info", fmt.Sprintf("Successfully sent message to %s", contact.Name))
+				if attachmentSent {
+					kind := sendOpts.Attachment
+					if kind == "" {
+						kind = AttachmentImage
+					}
+					attachmentsSent[kind]++
+				}
 
-			// Mark as completed
-			if err := tracker.MarkCompleted(contact); err != nil {
-				Log("warn", fmt.Sprintf("Failed to mark %s as completed: %v", contact.PhoneNumber, err))
+				// Mark as completed
+				if err := tracker.MarkCompleted(contact); err != nil {
+					automessage.Log("warn", fmt.Sprintf("Failed to mark %s as completed: %v", contact.PhoneNumber, err))
+				}
 			}
 
-			results = append(results, MessageResult{
-				Contact: contact,
-				Success: true,
-				Error:   nil,
+			recordResult(MessageResult{
+				Contact:    contact,
+				Success:    true,
+				Error:      nil,
+				Screenshot: screenshot,
 			})
 			successCount++
 		}
@@ -149,26 +854,254 @@ func main() {
 	duration := time.Since(startTime)
 
 	// Print summary
-	Log("info", "=== Automation Summary ===")
-	Log("info", fmt.Sprintf("Total contacts: %d", len(contacts)))
-	Log("info", fmt.Sprintf("Successful: %d", successCount))
-	Log("info", fmt.Sprintf("Failed: %d", failureCount))
-	Log("info", fmt.Sprintf("Skipped (already sent): %d", skippedCount))
-	Log("info", fmt.Sprintf("Duration: %v", duration))
+	automessage.Log("info", "=== Automation Summary ===")
+	automessage.Log("info", fmt.Sprintf("Total contacts: %d", total))
+	automessage.Log("info", fmt.Sprintf("Successful: %d", successCount))
+	automessage.Log("info", fmt.Sprintf("Failed: %d", failureCount))
+	automessage.Log("info", fmt.Sprintf("Skipped (already sent): %d", skippedCount))
+	if notProcessedCount > 0 {
+		automessage.Log("warn", fmt.Sprintf("Not processed (run stopped early): %d", notProcessedCount))
+	}
+	if excludedCount > 0 {
+		automessage.Log("info", fmt.Sprintf("Excluded (in %s): %d", config.Files.ExcludeCSV, excludedCount))
+	}
+	if skippedUnverifiedCount > 0 {
+		automessage.Log("info", fmt.Sprintf("Skipped (previously unverified): %d", skippedUnverifiedCount))
+	}
+	if alreadyInChatCount > 0 {
+		automessage.Log("info", fmt.Sprintf("Skipped (already in chat): %d", alreadyInChatCount))
+	}
+	if operatorSkippedCount > 0 {
+		automessage.Log("info", fmt.Sprintf("Skipped (by operator): %d", operatorSkippedCount))
+	}
+	if textOnlyCount > 0 {
+		automessage.Log("info", fmt.Sprintf("Downgraded to text-only: %d", textOnlyCount))
+	}
+	if mix := attachmentBreakdown(attachmentsSent); mix != "" {
+		automessage.Log("info", fmt.Sprintf("Attachments sent: %s", mix))
+	}
+	for _, line := range typeBreakdown(results) {
+		automessage.Log("info", line)
+	}
+	for _, line := range variantBreakdown(results, msgTemplate) {
+		automessage.Log("info", line)
+	}
+	if *resendChanged {
+		sentChanged := 0
+		for _, result := range results {
+			if result.Success && changedContacts[automessage.CleanPhoneNumber(result.Contact.PhoneNumber)] {
+				sentChanged++
+			}
+		}
+		automessage.Log("info", fmt.Sprintf("Successful by bucket: %d new, %d changed since last send (%d unchanged skipped)",
+			successCount-sentChanged, sentChanged, skippedCount))
+	}
+	if partialCount > 0 {
+		automessage.Log("warn", fmt.Sprintf("PARTIAL (first message sent, a follow-up failed): %d", partialCount))
+	}
+	if unverifiedCount > 0 {
+		automessage.Log("warn", fmt.Sprintf("SENT BUT UNVERIFIED: %d (recorded in %s, check these chats manually)",
+			unverifiedCount, config.Files.UnverifiedCSVPath))
+	}
+	automessage.Log("info", fmt.Sprintf("Duration: %v", duration))
+	if averages := whatsappClient.PhaseAverages(); len(averages) > 0 {
+		parts := make([]string, 0, len(averages))
+		for _, avg := range averages {
+			parts = append(parts, fmt.Sprintf("%s %v", avg.name, avg.duration.Round(100*time.Millisecond)))
+		}
+		automessage.Log("info", fmt.Sprintf("Average time per send attempt: %s", strings.Join(parts, ", ")))
+
+		// Measured times make the next run's estimate more accurate
+		if err := saveRunStats(config.Files.RunStatsPath, len(results), averages); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Failed to save run stats: %v", err))
+		}
+	}
+	selectorLines, staleSelectors := whatsappClient.SelectorReport()
+	for _, line := range selectorLines {
+		automessage.Log("info", fmt.Sprintf("Selector usage - %s", line))
+	}
+	for _, warning := range staleSelectors {
+		automessage.Log("warn", fmt.Sprintf("Selector usage - %s", warning))
+	}
 
 	if failureCount > 0 {
-		Log("warn", "\nFailed contacts:")
+		automessage.Log("warn", "\nFailed contacts:")
 		for _, result := range results {
-			if !result.Success {
-				Log("warn", fmt.Sprintf("  - %s (%s): %v",
+			if !result.Success && !result.Unverified && result.Status() == "failed" {
+				automessage.Log("warn", fmt.Sprintf("  - %s (%s): %v",
 					result.Contact.Name, result.Contact.PhoneNumber, result.Error))
 			}
 		}
+
+		// Data problems are listed separately so they can go back to the data team
+		for _, status := range []string{"not_on_whatsapp", "invalid_format"} {
+			var matching []MessageResult
+			for _, result := range results {
+				if result.Status() == status {
+					matching = append(matching, result)
+				}
+			}
+			if len(matching) == 0 {
+				continue
+			}
+			if status == "not_on_whatsapp" {
+				automessage.Log("warn", fmt.Sprintf("\nNot on WhatsApp (%d):", len(matching)))
+			} else {
+				automessage.Log("warn", fmt.Sprintf("\nInvalid phone number format (%d):", len(matching)))
+			}
+			for _, result := range matching {
+				automessage.Log("warn", fmt.Sprintf("  - %s (%s)", result.Contact.Name, result.Contact.PhoneNumber))
+			}
+		}
 	}
 
-	Log("info", "WhatsApp Automation completed")
+	if partialCount > 0 {
+		automessage.Log("warn", "\nPartial sends (first message sent, a follow-up failed - send the rest manually):")
+		for _, result := range results {
+			if result.Status() == "partial" {
+				automessage.Log("warn", fmt.Sprintf("  - %s (%s): %v",
+					result.Contact.Name, result.Contact.PhoneNumber, result.Error))
+			}
+		}
+	}
 
-	if failureCount > 0 {
+	if unverifiedCount > 0 {
+		automessage.Log("warn", "\nUnverified contacts:")
+		for _, result := range results {
+			if result.Unverified {
+				automessage.Log("warn", fmt.Sprintf("  - %s (%s)", result.Contact.Name, result.Contact.PhoneNumber))
+			}
+		}
+	}
+
+	if *reportHTML != "" {
+		if err := WriteHTMLReport(*reportHTML, results); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Failed to write HTML report: %v", err))
+		} else {
+			automessage.Log("info", fmt.Sprintf("HTML report written to %s", *reportHTML))
+		}
+	}
+
+	automessage.Log("info", "WhatsApp Automation completed")
+
+	failures := make([]MessageResult, 0, failureCount+partialCount)
+	for _, result := range results {
+		if !result.Success && !result.Unverified {
+			failures = append(failures, result)
+		}
+	}
+	summary := RunSummary{
+		Total:      total,
+		Successful: successCount,
+		Failed:     failureCount,
+		Skipped:    skippedCount + skippedUnverifiedCount + alreadyInChatCount + operatorSkippedCount,
+		Excluded:   excludedCount,
+		Unverified: unverifiedCount,
+		Partial:    partialCount,
+		Duration:   duration,
+		Failures:   failures,
+	}
+	if stopReason != "" {
+		summary.Aborted = true
+		summary.AbortReason = stopReason
+	}
+	NotifyCompletion(config.Notifications, summary)
+
+	// On-phone confirmation through the logged-in account itself
+	if config.Notifications.SummaryToSelf && !*dryRun {
+		if config.Sandbox() {
+			automessage.Log("info", "Skipping the summary to self in the sandbox environment, which never sends")
+		} else if err := whatsappClient.SendToSelf(config.Notifications.SelfPhone, summary.Text()); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Failed to send summary to self: %v", err))
+		} else {
+			automessage.Log("info", "Summary sent to your own WhatsApp chat")
+		}
+	}
+
+	if failureCount > 0 || partialCount > 0 || failedFast || readErr != nil {
 		os.Exit(1)
 	}
 }
+
+// abortRun logs a fatal error, notifies operators that the run was aborted
+// and exits with a non-zero status
+func abortRun(config *automessage.Config, reason string) {
+	automessage.Log("error", reason)
+	NotifyCompletion(config.Notifications, RunSummary{
+		Aborted:     true,
+		AbortReason: reason,
+	})
+	os.Exit(1)
+}
+
+// typeBreakdown summarizes results per contact type, for lists that mix
+// persons and groups. Returns nothing when every contact is a person.
+func typeBreakdown(results []MessageResult) []string {
+	type counts struct{ successful, unverified, failed int }
+	byType := make(map[automessage.ContactType]*counts)
+	for _, result := range results {
+		contactType := result.Contact.Type
+		if contactType == "" {
+			contactType = automessage.ContactPerson
+		}
+		c := byType[contactType]
+		if c == nil {
+			c = &counts{}
+			byType[contactType] = c
+		}
+		switch {
+		case result.Success:
+			c.successful++
+		case result.Unverified:
+			c.unverified++
+		default:
+			c.failed++
+		}
+	}
+	if byType[automessage.ContactGroup] == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, contactType := range []automessage.ContactType{automessage.ContactPerson, automessage.ContactGroup} {
+		if c := byType[contactType]; c != nil {
+			label := map[automessage.ContactType]string{automessage.ContactPerson: "Persons", automessage.ContactGroup: "Groups"}[contactType]
+			lines = append(lines, fmt.Sprintf("%s: %d successful, %d unverified, %d failed",
+				label, c.successful, c.unverified, c.failed))
+		}
+	}
+	return lines
+}
+
+// variantBreakdown summarizes results per template variant, for A/B runs
+// with template.variants
+func variantBreakdown(results []MessageResult, msgTemplate *automessage.MessageTemplate) []string {
+	if !msgTemplate.HasVariants() {
+		return nil
+	}
+
+	type counts struct{ successful, unverified, failed int }
+	byVariant := make(map[string]*counts)
+	for _, name := range msgTemplate.VariantNames() {
+		byVariant[name] = &counts{}
+	}
+	for _, result := range results {
+		c := byVariant[msgTemplate.VariantFor(result.Contact)]
+		switch {
+		case result.Success:
+			c.successful++
+		case result.Unverified:
+			c.unverified++
+		default:
+			c.failed++
+		}
+	}
+
+	var lines []string
+	for _, name := range msgTemplate.VariantNames() {
+		c := byVariant[name]
+		lines = append(lines, fmt.Sprintf("Variant %s: %d successful, %d unverified, %d failed",
+			name, c.successful, c.unverified, c.failed))
+	}
+	return lines
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"whatsapp-automation/automessage"
+)
+
+// mockWhatsAppPage is a minimal stand-in for WhatsApp Web with the elements
+// the send flow relies on: the #side panel, a chat header showing the number
+// from the URL, the message input box, a send button that is only enabled
+// when there is text, and message bubbles that get appended when Enter is
+// pressed.
+const mockWhatsAppPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title>Mock WhatsApp</title>
+<style>
+#side { width: 200px; float: left; height: 600px; background: #eee; }
+#main { margin-left: 220px; }
+#input { border: 1px solid #999; min-height: 40px; padding: 4px; }
+</style>
+</head>
+<body>
+<div id="side">Chats</div>
+<div id="main">
+  <header><span id="chat-title" dir="auto"></span></header>
+  <div id="messages"></div>
+  <footer class="copyable-text">
+    <div id="input" contenteditable="true" role="textbox" data-tab="10" title="Type a message"></div>
+    <button id="send" aria-label="Send" disabled><span data-icon="send">Send</span></button>
+  </footer>
+</div>
+<script>
+const phone = new URLSearchParams(location.search).get('phone');
+document.getElementById('chat-title').textContent = phone ? '+' + phone : '';
+
+const input = document.getElementById('input');
+const sendButton = document.getElementById('send');
+const messages = document.getElementById('messages');
+
+function updateSendButton() {
+  sendButton.disabled = input.innerText.trim() === '';
+}
+
+function sendMessage() {
+  const text = input.innerText.trim();
+  if (text === '') return;
+  const bubble = document.createElement('div');
+  bubble.className = 'message-out';
+  bubble.setAttribute('data-id', 'true_' + phone + '_' + messages.children.length);
+  const body = document.createElement('div');
+  body.setAttribute('data-pre-plain-text', '[' + new Date().toLocaleTimeString() + '] Me: ');
+  const span = document.createElement('span');
+  span.className = 'selectable-text';
+  span.innerText = text;
+  body.appendChild(span);
+  const status = document.createElement('span');
+  status.setAttribute('data-icon', 'msg-check');
+  bubble.appendChild(body);
+  bubble.appendChild(status);
+  messages.appendChild(bubble);
+  input.innerHTML = '';
+  updateSendButton();
+}
+
+input.addEventListener('input', updateSendButton);
+input.addEventListener('keyup', updateSendButton);
+input.addEventListener('keydown', function (e) {
+  if (e.key === 'Enter' && !e.shiftKey) {
+    e.preventDefault();
+    sendMessage();
+  }
+});
+sendButton.addEventListener('click', sendMessage);
+</script>
+</body>
+</html>
+`
+
+// startMockWhatsApp serves the mock WhatsApp Web page on a local port.
+// Both the root page and /send?phone=... serve the same chat view.
+func startMockWhatsApp(t *testing.T) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, mockWhatsAppPage)
+	})
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// mockConfig loads a headless config pointed at the mock server, with the
+// browser session and trackers in the test's temp directory
+func mockConfig(t *testing.T, server *httptest.Server) *automessage.Config {
+	dir := t.TempDir()
+	yaml := fmt.Sprintf(`browser:
+  headless: true
+  web_url: %q
+  send_url_base: %q
+  skip_network_check: true
+  user_data_dir: %q
+files:
+  completed_csv_path: %q
+  unverified_csv_path: %q
+logging:
+  level: "info"
+`, server.URL, server.URL+"/send", filepath.Join(dir, "chrome-data"),
+		filepath.Join(dir, "completed.csv"), filepath.Join(dir, "unverified.csv"))
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := automessage.LoadConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// TestSendMessageMockWhatsApp runs the real browser send flow against the
+// mock page: open the chat, type, press Enter and verify the new bubble.
+// It needs a Chromium-based browser and is skipped without one.
+func TestSendMessageMockWhatsApp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("browser test skipped in -short mode")
+	}
+	if automessage.FindChromePath("") == "" {
+		t.Skip("no Chromium-based browser found")
+	}
+
+	server := startMockWhatsApp(t)
+	client := NewWhatsAppClient(mockConfig(t, server))
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.SendMessage("+15102168856", "Hello from the mock test", SendOptions{TextOnly: true}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// RunSummary holds the final statistics of a run for notifications
+type RunSummary struct {
+	Total       int
+	Successful  int
+	Failed      int
+	Skipped     int
+	Excluded    int
+	Unverified  int
+	Partial     int
+	Duration    time.Duration
+	Failures    []MessageResult
+	Aborted     bool
+	AbortReason string
+}
+
+// Text renders the summary as a plain-text message
+func (s RunSummary) Text() string {
+	var b strings.Builder
+
+	if s.Aborted {
+		b.WriteString("WhatsApp Automation ABORTED\n")
+		b.WriteString(fmt.Sprintf("Reason: %s\n", s.AbortReason))
+	} else {
+		b.WriteString("WhatsApp Automation completed\n")
+	}
+
+	b.WriteString(fmt.Sprintf("Total contacts: %d\n", s.Total))
+	b.WriteString(fmt.Sprintf("Successful: %d\n", s.Successful))
+	b.WriteString(fmt.Sprintf("Failed: %d\n", s.Failed))
+	b.WriteString(fmt.Sprintf("Skipped (already sent): %d\n", s.Skipped))
+	if s.Excluded > 0 {
+		b.WriteString(fmt.Sprintf("Excluded: %d\n", s.Excluded))
+	}
+	if s.Unverified > 0 {
+		b.WriteString(fmt.Sprintf("Sent but unverified: %d\n", s.Unverified))
+	}
+	if s.Partial > 0 {
+		b.WriteString(fmt.Sprintf("Partial (first message sent, a follow-up failed): %d\n", s.Partial))
+	}
+	b.WriteString(fmt.Sprintf("Duration: %v\n", s.Duration.Round(time.Second)))
+
+	if len(s.Failures) > 0 {
+		b.WriteString("\nFailed contacts:\n")
+		for _, result := range s.Failures {
+			b.WriteString(fmt.Sprintf("  - %s (%s): %v\n",
+				result.Contact.Name, result.Contact.PhoneNumber, result.Error))
+		}
+	}
+
+	return b.String()
+}
+
+// NotifyCompletion sends the run summary to every configured channel.
+// Failures are only logged and never affect the run's outcome; the whole
+// call is bounded by the configured timeout.
+func NotifyCompletion(config automessage.NotificationsConfig, summary RunSummary) {
+	subject := "WhatsApp Automation completed"
+	if summary.Aborted {
+		subject = "WhatsApp Automation ABORTED"
+	}
+	notify(config.OnComplete, subject, summary.Text())
+}
+
+// NotifyAlert sends a mid-run alert to the on_complete channels, so the
+// operator hears about trouble without waiting for the end of the run
+func NotifyAlert(config automessage.NotificationsConfig, subject, text string) {
+	notify(config.OnComplete, subject, subject+"\n"+text)
+}
+
+// notify sends text to the Slack webhook and email recipients configured,
+// waiting at most the configured timeout
+func notify(onComplete automessage.OnCompleteConfig, subject, text string) {
+	if onComplete.SlackWebhookURL == "" && onComplete.Email.SMTPHost == "" {
+		return
+	}
+
+	timeout := time.Duration(onComplete.TimeoutSeconds) * time.Second
+
+	var wg sync.WaitGroup
+	if onComplete.SlackWebhookURL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sendSlackNotification(onComplete.SlackWebhookURL, text, timeout); err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to send Slack notification: %v", err))
+			} else {
+				automessage.Log("info", "Slack notification sent")
+			}
+		}()
+	}
+	if onComplete.Email.SMTPHost != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sendEmailNotification(onComplete.Email, subject, text); err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to send email notification: %v", err))
+			} else {
+				automessage.Log("info", "Email notification sent")
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		automessage.Log("warn", fmt.Sprintf("Notifications did not finish within %v, giving up", timeout))
+	}
+}
+
+// sendSlackNotification posts the summary to a Slack incoming webhook
+func sendSlackNotification(webhookURL, text string, timeout time.Duration) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack payload: %w", err)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Slack webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sendEmailNotification sends text as a plain-text email over SMTP
+func sendEmailNotification(config automessage.EmailConfig, subject, text string) error {
+	if len(config.To) == 0 {
+		return fmt.Errorf("no email recipients configured")
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", config.From))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(config.To, ", ")))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost)
+	}
+
+	addr := fmt.Sprintf("%s:%d", config.SMTPHost, config.SMTPPort)
+	if err := smtp.SendMail(addr, auth, config.From, config.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", addr, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// PlanEntry is the projected send of one contact
+type PlanEntry struct {
+	Contact  automessage.Contact
+	Priority string
+	Sends    int       // Messages this contact gets, more than 1 with message_separator or files.images
+	At       time.Time // Projected start of the contact's first send
+	Done     time.Time // Projected end of the contact's last send
+	Err      error     // Why the contact would fail without sending, if it would
+}
+
+// BuildPlan projects when each contact would be sent if a run started at
+// start, using the same pacing as a real run: rate limit, delay ramp,
+// priority delays and batch cooldowns. sendDuration is the time one send
+// itself takes in the browser, which the config can't know.
+func BuildPlan(config *automessage.Config, contacts []automessage.Contact, msgTemplate *automessage.MessageTemplate, start time.Time, sendDuration time.Duration) []PlanEntry {
+	var minInterval time.Duration
+	if config.RateLimiting.Enabled && config.RateLimiting.MessagesPerSecond > 0 {
+		minInterval = time.Second / time.Duration(config.RateLimiting.MessagesPerSecond)
+	}
+	batchSize := config.RateLimiting.BatchSize
+	batchCooldown := time.Duration(config.RateLimiting.BatchCooldownMinutes * float64(time.Minute))
+
+	entries := make([]PlanEntry, 0, len(contacts))
+	at := start
+	sendIndex := 0
+	sentInBatch := 0
+	for _, contact := range contacts {
+		entry := PlanEntry{Contact: contact}
+		name, priority, err := automessage.ContactPriority(config, contact)
+		entry.Priority = name
+		if err != nil {
+			entry.Err = err
+			entries = append(entries, entry)
+			continue
+		}
+		message, err := msgTemplate.Render(contact)
+		if err != nil {
+			entry.Err = err
+			entries = append(entries, entry)
+			continue
+		}
+		entry.Sends = len(msgTemplate.SplitParts(message))
+		if contact.Media != automessage.MediaText && contact.Media != automessage.MediaNone {
+			entry.Sends += len(config.Files.Images) // Each carousel image is its own send
+		}
+
+		if batchSize > 0 {
+			if sentInBatch == batchSize {
+				at = at.Add(batchCooldown)
+				sentInBatch = 0
+			}
+			sentInBatch++
+		}
+
+		for part := 0; part < entry.Sends; part++ {
+			at = at.Add(rampDelayFor(config.RateLimiting.Ramp, sendIndex))
+			if part == 0 {
+				at = at.Add(time.Duration(priority.ExtraDelaySeconds * float64(time.Second)))
+				entry.At = at
+			}
+			sendIndex++
+
+			step := sendDuration
+			if step < minInterval {
+				step = minInterval
+			}
+			at = at.Add(step)
+		}
+		entry.Done = at
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// planEnd returns when the last planned send finishes, or start if nothing
+// would be sent
+func planEnd(entries []PlanEntry, start time.Time) time.Time {
+	end := start
+	for _, entry := range entries {
+		if entry.Err == nil {
+			end = entry.Done
+		}
+	}
+	return end
+}
+
+// WritePlan writes the plan as an aligned table, or as CSV with format "csv".
+// The day column counts from the start day, so anything above 0 can't be
+// sent on the day the run starts.
+func WritePlan(w io.Writer, entries []PlanEntry, start time.Time, format string) error {
+	header := []string{"#", "name", "phone_number", "priority", "sends", "projected_time", "day", "note"}
+	startDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+
+	rows := make([][]string, 0, len(entries))
+	for i, entry := range entries {
+		row := []string{strconv.Itoa(i + 1), entry.Contact.Name, entry.Contact.PhoneNumber, entry.Priority}
+		if entry.Err != nil {
+			row = append(row, "0", "", "", fmt.Sprintf("would fail: %v", entry.Err))
+		} else {
+			day := int(entry.At.Sub(startDay).Hours() / 24)
+			row = append(row, strconv.Itoa(entry.Sends), entry.At.Format("2006-01-02 15:04:05"), strconv.Itoa(day), "")
+		}
+		rows = append(rows, row)
+	}
+
+	if format == "csv" {
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
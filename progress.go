@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// progressWindow is how many recent contacts the ETA's moving average covers
+const progressWindow = 20
+
+// ProgressEstimator keeps a rolling window of per-contact durations (including
+// retries, pacing and cooldowns) to estimate the time remaining in a run
+type ProgressEstimator struct {
+	durations []time.Duration
+}
+
+// Add records how long one contact took
+func (p *ProgressEstimator) Add(d time.Duration) {
+	p.durations = append(p.durations, d)
+	if len(p.durations) > progressWindow {
+		p.durations = p.durations[1:]
+	}
+}
+
+// Average returns the moving-average time per contact
+func (p *ProgressEstimator) Average() time.Duration {
+	if len(p.durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range p.durations {
+		total += d
+	}
+	return total / time.Duration(len(p.durations))
+}
+
+// ETA estimates the time needed for the remaining contacts
+func (p *ProgressEstimator) ETA(remaining int) time.Duration {
+	return p.Average() * time.Duration(remaining)
+}
+
+// formatProgress renders a progress line with counts, failure rate and ETA
+func formatProgress(processed, total, sent, failed, skipped, unverified int, estimator *ProgressEstimator) string {
+	attempted := sent + failed + unverified
+	failureRate := 0.0
+	if attempted > 0 {
+		failureRate = float64(failed) / float64(attempted) * 100
+	}
+
+	if total <= 0 {
+		// Streaming: the total isn't known, so there is no ETA
+		return fmt.Sprintf("Progress: %d processed (%d sent, %d failed, %d unverified, %d skipped), failure rate %.1f%%, avg %v/contact",
+			processed, sent, failed, unverified, skipped, failureRate,
+			estimator.Average().Round(100*time.Millisecond))
+	}
+
+	eta := estimator.ETA(total - processed)
+	return fmt.Sprintf("Progress: %d/%d processed (%d sent, %d failed, %d unverified, %d skipped), failure rate %.1f%%, avg %v/contact, ETA %v (~%s)",
+		processed, total, sent, failed, unverified, skipped, failureRate,
+		estimator.Average().Round(100*time.Millisecond), eta.Round(time.Second),
+		time.Now().Add(eta).Format("15:04"))
+}
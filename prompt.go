@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stdinReader is shared by every prompt, so input a previous prompt's reader
+// buffered past its newline isn't lost to the next one
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// stdinIsTerminal reports whether stdin is an interactive terminal, so a
+// prompt has someone to answer it
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptYesNo asks a yes/no question on the terminal. An empty answer
+// returns defaultYes.
+func promptYesNo(question string, defaultYes bool) bool {
+	options := "[y/N]"
+	if defaultYes {
+		options = "[Y/n]"
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ", question, options) // Keeps -stream-results output on stdout clean
+
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return defaultYes
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// reviewAction is the operator's answer when reviewing a composed message
+type reviewAction int
+
+const (
+	reviewSend reviewAction = iota
+	reviewSkip
+	reviewEdit
+)
+
+// promptReview asks the operator what to do with the message composed in
+// the browser for label: Enter sends, "s" skips, "e" edits it in Chrome first
+func promptReview(label string, edited bool) reviewAction {
+	question := fmt.Sprintf("Message for %s is in the input box. [Enter] send, [s] skip, [e] edit in browser: ", label)
+	if edited {
+		question = fmt.Sprintf("Edit the message for %s in Chrome, then [Enter] send or [s] skip: ", label)
+	}
+	fmt.Fprint(os.Stderr, question) // Keeps -stream-results output on stdout clean
+
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return reviewSkip // No one to answer, never send unreviewed
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return reviewSend
+	case "e", "edit":
+		return reviewEdit
+	case "s", "skip":
+		return reviewSkip
+	default:
+		fmt.Fprintln(os.Stderr, "Please answer Enter, s or e")
+		return promptReview(label, edited)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/chromedp/chromedp"
+
+	"whatsapp-automation/automessage"
+)
+
+// ErrWrongRecipient is returned when browser.verify_recipient finds that the
+// opened chat isn't the intended recipient's. It is never retried.
+var ErrWrongRecipient = errors.New("opened chat does not belong to the recipient")
+
+// verifyRecipient checks the open chat's title against the recipient before
+// anything is typed. Saved contacts show their name instead of the number,
+// so the title may match either; a title that can't be read fails the check
+// rather than risk a send to the wrong chat.
+func (c *WhatsAppClient) verifyRecipient(phoneNumber string, opts SendOptions) error {
+	// Only the chat title: the rest of the header holds the status line,
+	// "typing..." and group member lists, which can contain anyone's name
+	var title string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				const header = document.querySelector('#main header');
+				if (!header) return '';
+				const el = header.querySelector('[data-testid="conversation-info-header-chat-title"]') ||
+					header.querySelector('span[dir="auto"]');
+				if (!el) return '';
+				return el.getAttribute('title') || el.textContent || '';
+			})()
+		`, &title),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: could not read the chat title: %v", ErrWrongRecipient, err)
+	}
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("%w: chat title not found", ErrWrongRecipient)
+	}
+
+	var names []string
+	if !opts.Group {
+		names = append(names, opts.Recipient)
+	}
+	names = append(names, opts.SearchTerm)
+	if recipientMatches(title, phoneNumber, names...) {
+		automessage.Log("debug", fmt.Sprintf("Chat title matches %s", phoneNumber))
+		return nil
+	}
+	return fmt.Errorf("%w: chat title is %q", ErrWrongRecipient, title)
+}
+
+// recipientMatches reports whether the chat title is the phone number,
+// ignoring formatting, or exactly one of the names, ignoring case and
+// spacing. A title that is a number only ever matches the number.
+func recipientMatches(title, phoneNumber string, names ...string) bool {
+	// WhatsApp shows numbers formatted and wrapped in direction marks,
+	// compare the digits only
+	if isPhoneTitle(title) {
+		number := automessage.DigitsOnly(automessage.ASCIIDigits(phoneNumber))
+		return number != "" && automessage.DigitsOnly(automessage.ASCIIDigits(title)) == number
+	}
+
+	title = normalizeName(title)
+	for _, name := range names {
+		if name = normalizeName(name); name != "" && name == title {
+			return true
+		}
+	}
+	return false
+}
+
+// isPhoneTitle reports whether a chat title is a phone number: digits with
+// nothing but number formatting and invisible direction marks around them
+func isPhoneTitle(title string) bool {
+	digits := false
+	for _, r := range title {
+		switch {
+		case unicode.IsDigit(r):
+			digits = true
+		case unicode.IsSpace(r), unicode.Is(unicode.Cf, r), strings.ContainsRune("+-().", r):
+		default:
+			return false
+		}
+	}
+	return digits
+}
+
+// normalizeName lowercases a name and collapses its whitespace
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
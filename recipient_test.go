@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRecipientMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		names []string
+		want  bool
+	}{
+		{"formatted number", "+1 (510) 216-8856", nil, true},
+		{"number in direction marks", "\u202a+1 510-216-8856\u202c", nil, true},
+		{"other number", "+1 510-216-8857", []string{"Dana"}, false},
+		{"number title needs the number", "+1 510-216-8857", []string{"+1 510-216-8857"}, false},
+		{"exact name", "Dana  levi", []string{"dana Levi"}, true},
+		{"name contained in title", "Dana Levi", []string{"Dana"}, false},
+		{"title contained in name", "Dana", []string{"Dana Levi"}, false},
+		{"second name", "Book Club", []string{"Dana", "book club"}, true},
+		{"empty name", "Dana", []string{""}, false},
+		{"name with digits", "Dana 2", []string{"Dana 2"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recipientMatches(tt.title, "+1 510 216 8856", tt.names...); got != tt.want {
+				t.Errorf("recipientMatches(%q, %q) = %v, want %v", tt.title, tt.names, got, tt.want)
+			}
+		})
+	}
+}
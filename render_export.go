@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"whatsapp-automation/automessage"
+)
+
+// RenderPackage writes each contact's rendered message to files in outDir,
+// named by phone number, plus a manifest.csv listing the contact, chat URL,
+// message files and resolved image path, so the messages can be sent by a
+// person or another system. It returns the number of contacts that could
+// not be rendered.
+func RenderPackage(outDir string, config *automessage.Config, contacts []automessage.Contact, templates *automessage.RunTemplates) (int, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create render output directory: %w", err)
+	}
+
+	manifestFile, err := os.Create(filepath.Join(outDir, "manifest.csv"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create manifest: %w", err)
+	}
+	defer manifestFile.Close()
+
+	manifest := csv.NewWriter(manifestFile)
+	if err := manifest.Write([]string{"name", "phone_number", "chat_url", "image_path", "caption_file", "message_files", "error"}); err != nil {
+		return 0, fmt.Errorf("failed to write manifest header: %w", err)
+	}
+
+	invalid := 0
+	used := make(map[string]int) // Base file names taken so far, for duplicate numbers
+	for _, contact := range contacts {
+		record, err := renderContactFiles(outDir, config, contact, used, templates)
+		if err != nil {
+			automessage.Log("warn", fmt.Sprintf("Could not render %s (%s): %v", contact.Name, contact.PhoneNumber, err))
+			record = []string{contact.Name, contact.PhoneNumber, "", "", "", "", err.Error()}
+			invalid++
+		}
+		if err := manifest.Write(record); err != nil {
+			return invalid, fmt.Errorf("failed to write manifest record: %w", err)
+		}
+	}
+
+	manifest.Flush()
+	if err := manifest.Error(); err != nil {
+		return invalid, fmt.Errorf("failed to flush manifest: %w", err)
+	}
+	return invalid, nil
+}
+
+// renderContactFiles writes one contact's message (one file per part when
+// the template is split) and captions, and returns its manifest record
+func renderContactFiles(outDir string, config *automessage.Config, contact automessage.Contact, used map[string]int, templates *automessage.RunTemplates) ([]string, error) {
+	group := contact.Type == automessage.ContactGroup
+	if !group {
+		if err := automessage.ValidatePhoneNumber(contact.PhoneNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	// Composed exactly as a run would, so a missing per-contact image is a
+	// text-only contact here too, and the attachment column counts
+	send, err := composeContactSend(contact, config, templates)
+	if err != nil {
+		return nil, err
+	}
+	if send.TextOnlyReason != "" {
+		automessage.Log(send.TextOnlyLevel, fmt.Sprintf("Rendering text only for %s: %s", contact.ChatLabel(), send.TextOnlyReason))
+	}
+	parts, carousel := send.Parts, send.Carousel
+
+	// Absolute so the package can be used from anywhere
+	var imagePath string
+	if send.ImageSend() {
+		if imagePath, err = filepath.Abs(send.Opts.ImagePath); err != nil {
+			return nil, err
+		}
+	}
+
+	base := automessage.CleanPhoneNumber(contact.PhoneNumber)
+	if group {
+		base = "group_" + groupFileName(contact.Name)
+	}
+	used[base]++
+	if used[base] > 1 {
+		base = fmt.Sprintf("%s_%d", base, used[base])
+	}
+
+	// A caption of its own goes to the caption file, and the message files
+	// hold only what follows it as text
+	if send.SeparateCaption && len(carousel) == 0 {
+		parts = send.FollowUps
+	}
+	var messageFiles []string
+	for i, part := range parts {
+		name := base + ".txt"
+		if len(parts) > 1 {
+			name = fmt.Sprintf("%s-%d.txt", base, i+1)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, name), []byte(part), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write message file: %w", err)
+		}
+		messageFiles = append(messageFiles, name)
+	}
+
+	var captionFile string
+	if send.SeparateCaption {
+		captionFile = base + "-caption.txt"
+		if err := os.WriteFile(filepath.Join(outDir, captionFile), []byte(send.First), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write caption file: %w", err)
+		}
+	}
+
+	// Carousel images are listed in send order, each with its own caption
+	// file, even an empty one, so the two lists line up
+	imagePaths := []string{imagePath}
+	captionFiles := []string{captionFile}
+	if len(carousel) > 0 {
+		imagePaths, captionFiles = nil, nil
+	}
+	for i, image := range carousel {
+		absPath, err := filepath.Abs(image.Path)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("%s-image%d-caption.txt", base, i+1)
+		if err := os.WriteFile(filepath.Join(outDir, name), []byte(image.Caption), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write caption file: %w", err)
+		}
+		imagePaths = append(imagePaths, absPath)
+		captionFiles = append(captionFiles, name)
+	}
+
+	// Groups have no number, they are opened by searching for the name
+	chatURL := ""
+	if !group {
+		chatURL = buildChatURL(config.Browser.SendURLBase, contact.PhoneNumber)
+	}
+
+	return []string{
+		contact.Name,
+		automessage.NormalizePhoneNumber(contact.PhoneNumber),
+		chatURL,
+		strings.Join(imagePaths, ";"),
+		strings.Join(captionFiles, ";"),
+		strings.Join(messageFiles, ";"),
+		"",
+	}, nil
+}
+
+// groupFileName turns a group name into a file name: letters and digits
+// kept, anything else collapsed to a single underscore
+func groupFileName(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// reportRow is one contact's entry in the HTML report
+type reportRow struct {
+	Name        string
+	PhoneNumber string
+	Status      string
+	Error       string
+	Screenshot  template.URL // Inline data URI, empty if none
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title>WhatsApp Automation Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 8px; vertical-align: top; text-align: left; }
+.success { color: #1a7f37; }
+.failed { color: #cf222e; }
+.unverified { color: #9a6700; }
+.partial { color: #bc4c00; }
+img { max-width: 480px; }
+</style>
+</head>
+<body>
+<h1>WhatsApp Automation Report</h1>
+<p>Generated {{.Generated}}</p>
+<table>
+<tr><th>Name</th><th>Phone</th><th>Status</th><th>Preview</th></tr>
+{{range .Rows}}<tr>
+<td>{{.Name}}</td>
+<td>{{.PhoneNumber}}</td>
+<td class="{{.Status}}">{{.Status}}{{if .Error}}<br><small>{{.Error}}</small>{{end}}</td>
+<td>{{if .Screenshot}}<img src="{{.Screenshot}}" alt="preview">{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTMLReport writes a self-contained HTML report with each contact's
+// status and, where available, the preview screenshot embedded inline
+func WriteHTMLReport(filePath string, results []MessageResult) error {
+	rows := make([]reportRow, 0, len(results))
+	for _, result := range results {
+		row := reportRow{
+			Name:        result.Contact.Name,
+			PhoneNumber: result.Contact.PhoneNumber,
+			Status:      result.Status(),
+		}
+		if result.Error != nil {
+			row.Error = result.Error.Error()
+		}
+
+		if result.Screenshot != "" {
+			data, err := os.ReadFile(result.Screenshot)
+			if err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to embed screenshot %s: %v", result.Screenshot, err))
+			} else {
+				row.Screenshot = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer file.Close()
+
+	data := struct {
+		Generated string
+		Rows      []reportRow
+	}{
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Rows:      rows,
+	}
+
+	if err := htmlReportTemplate.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// streamedResult is the NDJSON representation of a MessageResult
+type streamedResult struct {
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// ResultStreamer writes each MessageResult as one JSON object per line
+// as soon as it is produced, so results can be piped into other tools
+type ResultStreamer struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewResultStreamer(w io.Writer) *ResultStreamer {
+	return &ResultStreamer{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Status returns a short machine-readable status for the result
+func (r MessageResult) Status() string {
+	switch {
+	case r.Unverified:
+		return "unverified"
+	case r.Success:
+		return "success"
+	case errors.Is(r.Error, ErrPartialSend):
+		return "partial"
+	case errors.Is(r.Error, ErrNotOnWhatsApp):
+		return "not_on_whatsapp"
+	case errors.Is(r.Error, ErrInvalidFormat):
+		return "invalid_format"
+	default:
+		return "failed"
+	}
+}
+
+func (rs *ResultStreamer) Write(result MessageResult) error {
+	record := streamedResult{
+		Name:        result.Contact.Name,
+		PhoneNumber: result.Contact.PhoneNumber,
+		Status:      result.Status(),
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.encoder.Encode(record)
+}
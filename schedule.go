@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"whatsapp-automation/automessage"
+)
+
+// countdownInterval is how often waitUntil logs the remaining time
+const countdownInterval = 1 * time.Minute
+
+// waitUntil blocks until the given start time, logging a countdown.
+// A start time in the past returns immediately with a warning.
+func waitUntil(start time.Time) {
+	remaining := time.Until(start)
+	if remaining <= 0 {
+		automessage.Log("warn", fmt.Sprintf("Scheduled start time %s is in the past, starting immediately",
+			start.Format(time.RFC3339)))
+		return
+	}
+
+	automessage.Log("info", fmt.Sprintf("Waiting until %s to start sending (%v from now)",
+		start.Format(time.RFC3339), remaining.Round(time.Second)))
+
+	for {
+		remaining = time.Until(start)
+		if remaining <= 0 {
+			break
+		}
+
+		if remaining > countdownInterval {
+			time.Sleep(countdownInterval)
+			if left := time.Until(start); left > 0 {
+				automessage.Log("info", fmt.Sprintf("Starting in %v...", left.Round(time.Second)))
+			}
+		} else {
+			time.Sleep(remaining)
+		}
+	}
+
+	automessage.Log("info", "Scheduled start time reached, starting to send")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// selectorStats counts, per kind of element (input, caption, send button),
+// which fallback selector matched. A primary selector that rarely matches
+// is an early sign that WhatsApp Web changed.
+type selectorStats struct {
+	hits map[string]map[int]int // kind -> selector index -> matches
+}
+
+// record notes that the selector at index (0-based) matched for kind
+func (s *selectorStats) record(kind string, index int) {
+	if s.hits == nil {
+		s.hits = make(map[string]map[int]int)
+	}
+	if s.hits[kind] == nil {
+		s.hits[kind] = make(map[int]int)
+	}
+	s.hits[kind][index]++
+}
+
+// report returns one line per kind, e.g. "input selector: #1 95% (19), #3 5%
+// (1)", plus a warning for each kind whose primary selector matched fewer
+// than half of the time
+func (s *selectorStats) report() (lines []string, stale []string) {
+	kinds := make([]string, 0, len(s.hits))
+	for kind := range s.hits {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		counts := s.hits[kind]
+		indices := make([]int, 0, len(counts))
+		total := 0
+		for index, n := range counts {
+			indices = append(indices, index)
+			total += n
+		}
+		sort.Ints(indices)
+
+		parts := make([]string, 0, len(indices))
+		for _, index := range indices {
+			parts = append(parts, fmt.Sprintf("#%d %.0f%% (%d)", index+1, float64(counts[index])/float64(total)*100, counts[index]))
+		}
+		lines = append(lines, fmt.Sprintf("%s selector: %s", kind, strings.Join(parts, ", ")))
+
+		if counts[0]*2 < total {
+			stale = append(stale, fmt.Sprintf("primary %s selector #1 matched only %d of %d times and may be stale", kind, counts[0], total))
+		}
+	}
+	return lines, stale
+}
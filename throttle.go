@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ThrottledError is returned when WhatsApp Web shows a rate-limit message.
+// RetryAfter is the wait it asked for, or zero if it didn't say.
+type ThrottledError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("throttled by WhatsApp (retry after %v): %s", e.RetryAfter, e.Message)
+	}
+	return fmt.Sprintf("throttled by WhatsApp: %s", e.Message)
+}
+
+// throttleTexts are lower-case substrings of the dialogs and toasts WhatsApp
+// Web shows when it is limiting how fast we send
+var throttleTexts = []string{
+	"try again later",
+	"try again in",
+	"sending messages too quickly",
+	"too many messages",
+	"temporarily limited",
+}
+
+// retryAfterPattern finds an explicit wait such as "try again in 5 minutes"
+var retryAfterPattern = regexp.MustCompile(`(\d+)\s*(second|sec|minute|min|hour|hr)s?\b`)
+
+// minThrottleBackoff is the least we wait after a throttle that didn't say
+// how long; a few seconds never helps
+const minThrottleBackoff = time.Minute
+
+// parseRetryAfter extracts an explicit wait from a throttle message
+func parseRetryAfter(text string) time.Duration {
+	match := retryAfterPattern.FindStringSubmatch(strings.ToLower(text))
+	if match == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	switch match[2] {
+	case "second", "sec":
+		return time.Duration(n) * time.Second
+	case "minute", "min":
+		return time.Duration(n) * time.Minute
+	default:
+		return time.Duration(n) * time.Hour
+	}
+}
+
+// detectThrottle looks for a rate-limit dialog, alert or toast on the page
+func (c *WhatsAppClient) detectThrottle() *ThrottledError {
+	var text string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const needles = %s;
+				const nodes = document.querySelectorAll('div[role="dialog"], div[role="alert"], [role="status"], [aria-live]');
+				for (const node of nodes) {
+					const text = (node.innerText || '').trim();
+					if (needles.some(n => text.toLowerCase().includes(n))) return text;
+				}
+				return '';
+			})()
+		`, jsStringArray(throttleTexts)), &text),
+	)
+	if err != nil || text == "" {
+		return nil
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	return &ThrottledError{Message: text, RetryAfter: parseRetryAfter(text)}
+}
+
+// throttleBackoff is how long to wait before retrying after a throttle:
+// WhatsApp's own figure when it gave one, otherwise the current retry delay
+// escalated twice as fast as normal. Both are capped by
+// retry.throttle_max_delay_seconds.
+func (c *WhatsAppClient) throttleBackoff(throttled *ThrottledError, retryDelay time.Duration) time.Duration {
+	delay := throttled.RetryAfter
+	if delay == 0 {
+		multiplier := c.config.Retry.BackoffMultiplier
+		delay = time.Duration(float64(retryDelay) * multiplier * multiplier)
+		if delay < minThrottleBackoff {
+			delay = minThrottleBackoff
+		}
+	}
+
+	maxDelay := time.Duration(c.config.Retry.ThrottleMaxDelaySeconds) * time.Second
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// phaseTiming is how long one phase of a send attempt took
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// sendTiming breaks a single send attempt down into phases
+type sendTiming struct {
+	phases []phaseTiming
+	mark   time.Time
+}
+
+func newSendTiming() *sendTiming {
+	return &sendTiming{mark: time.Now()}
+}
+
+// phase ends the current phase under the given name and starts the next
+func (t *sendTiming) phase(name string) {
+	now := time.Now()
+	t.phases = append(t.phases, phaseTiming{name: name, duration: now.Sub(t.mark)})
+	t.mark = now
+}
+
+func (t *sendTiming) String() string {
+	parts := make([]string, 0, len(t.phases))
+	for _, p := range t.phases {
+		parts = append(parts, fmt.Sprintf("%s=%v", p.name, p.duration.Round(10*time.Millisecond)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// phaseStats accumulates send timings over the run
+type phaseStats struct {
+	order []string
+	total map[string]time.Duration
+	count map[string]int
+}
+
+func (s *phaseStats) add(t *sendTiming) {
+	if s.total == nil {
+		s.total = make(map[string]time.Duration)
+		s.count = make(map[string]int)
+	}
+	for _, p := range t.phases {
+		if _, seen := s.count[p.name]; !seen {
+			s.order = append(s.order, p.name)
+		}
+		s.total[p.name] += p.duration
+		s.count[p.name]++
+	}
+}
+
+// averages returns the mean duration of each phase, in the order the
+// phases were first seen
+func (s *phaseStats) averages() []phaseTiming {
+	averages := make([]phaseTiming, 0, len(s.order))
+	for _, name := range s.order {
+		averages = append(averages, phaseTiming{name: name, duration: s.total[name] / time.Duration(s.count[name])})
+	}
+	return averages
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"whatsapp-automation/automessage"
+)
+
+// updatePromptTexts are lower-case substrings of the banners and dialogs
+// WhatsApp Web shows when a new version is waiting for a reload
+var updatePromptTexts = []string{
+	"new version available",
+	"new version of whatsapp",
+	"update available",
+	"click to update",
+	"reload to update",
+}
+
+// updateButtonTexts are the lower-case labels of the prompt's button
+var updateButtonTexts = []string{"update now", "update", "reload", "refresh"}
+
+// detectUpdatePrompt returns the text of an update prompt on the page, or ""
+// if there is none. The chat list is left out, so a message that happens to
+// mention an update doesn't count.
+func (c *WhatsAppClient) detectUpdatePrompt() string {
+	var text string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const needles = %s;
+				const nodes = document.querySelectorAll('div[role="dialog"], div[role="alert"], [role="status"], [aria-live], #side span, #side div[role="button"]');
+				for (const node of nodes) {
+					if (node.closest('#pane-side')) continue;
+					const text = (node.innerText || '').trim();
+					if (text.length > 300) continue;
+					if (needles.some(n => text.toLowerCase().includes(n))) return text;
+				}
+				return '';
+			})()
+		`, jsStringArray(updatePromptTexts)), &text),
+	)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// handleUpdatePrompt checks for a pending WhatsApp Web update between sends.
+// With browser.auto_reload_on_update it clicks the prompt's update button,
+// or reloads the page if there is no button, and waits for the chat list
+// again; the saved session means no QR scan. Otherwise it only warns.
+func (c *WhatsAppClient) handleUpdatePrompt() error {
+	prompt := c.detectUpdatePrompt()
+	if prompt == "" {
+		return nil
+	}
+	if !c.config.Browser.AutoReloadOnUpdate {
+		if c.updatePromptWarned {
+			return nil
+		}
+		c.updatePromptWarned = true
+		automessage.Log("warn", fmt.Sprintf("WhatsApp Web is asking to update (%q); set browser.auto_reload_on_update to reload automatically", prompt))
+		return nil
+	}
+
+	automessage.Log("info", fmt.Sprintf("WhatsApp Web update prompt detected (%q), reloading...", prompt))
+	c.takeScreenshot("update_prompt.png")
+
+	var clicked bool
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const needles = %s;
+				const labels = %s;
+				const nodes = document.querySelectorAll('div[role="dialog"], div[role="alert"], [role="status"], [aria-live], #side span, #side div[role="button"]');
+				for (const node of nodes) {
+					if (node.closest('#pane-side')) continue;
+					const text = (node.innerText || '').trim().toLowerCase();
+					if (text.length > 300 || !needles.some(n => text.includes(n))) continue;
+
+					// The prompt's own button, else the banner itself is the link
+					const scope = node.closest('div[role="dialog"], div[role="alert"]') || node.parentElement || node;
+					for (const button of scope.querySelectorAll('button, div[role="button"], span[role="button"], a')) {
+						const label = (button.innerText || button.getAttribute('aria-label') || '').trim().toLowerCase();
+						if (labels.some(l => label === l || label.startsWith(l))) {
+							button.click();
+							return true;
+						}
+					}
+					if (text.includes('click to update')) {
+						(node.closest('div[role="button"], button, a') || node).click();
+						return true;
+					}
+				}
+				return false;
+			})()
+		`, jsStringArray(updatePromptTexts), jsStringArray(updateButtonTexts)), &clicked),
+		chromedp.Sleep(2*time.Second),
+	)
+
+	if !clicked {
+		automessage.Log("debug", "No update button found, reloading the page instead")
+		err := chromedp.Run(c.ctx,
+			chromedp.Evaluate(`window.onbeforeunload = null;`, nil),
+			chromedp.Navigate(c.config.Browser.WebURL),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reload WhatsApp Web for an update: %w", err)
+		}
+	}
+
+	if err := c.waitForLogin(); err != nil {
+		return fmt.Errorf("WhatsApp Web did not load after updating: %w", err)
+	}
+
+	// Same settle as after the initial login
+	time.Sleep(3 * time.Second)
+	automessage.Log("info", "✓ WhatsApp Web updated, resuming sends")
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"whatsapp-automation/automessage"
+)
+
+// PrintChatURLs writes the chat URL each contact would be opened with,
+// followed by the rendered message, without launching a browser. It returns
+// the number of contacts whose phone number or message is invalid.
+func PrintChatURLs(w io.Writer, sendURLBase string, contacts []automessage.Contact, msgTemplate *automessage.MessageTemplate) int {
+	invalid := 0
+	for _, contact := range contacts {
+		if contact.Type == automessage.ContactGroup {
+			fmt.Fprintf(w, "%s\tgroup\t(opened by searching for the name)\n", contact.Name)
+		} else if err := automessage.ValidatePhoneNumber(contact.PhoneNumber); err != nil {
+			fmt.Fprintf(w, "%s\t%s\tINVALID: %v\n", contact.Name, contact.PhoneNumber, err)
+			invalid++
+			continue
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", contact.Name, contact.PhoneNumber, buildChatURL(sendURLBase, contact.PhoneNumber))
+		}
+
+		message, err := msgTemplate.Render(contact)
+		if err != nil {
+			fmt.Fprintf(w, "    RENDER ERROR: %v\n", err)
+			invalid++
+			continue
+		}
+		for _, line := range strings.Split(message, "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	return invalid
+}
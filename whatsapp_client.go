@@ -2,29 +2,206 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/inspector"
 	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+
+	"whatsapp-automation/automessage"
+)
+
+// ErrSendUnverified is returned when Enter was pressed and the message appears
+// to have left the input box, but no new bubble could be confirmed in the chat.
+// Such sends must not be retried, since the message may already have gone out.
+var ErrSendUnverified = errors.New("message may have been sent but could not be verified")
+
+// ErrInvalidFormat is returned when the phone number itself is malformed.
+// It is never retried.
+var ErrInvalidFormat = errors.New("invalid phone number format")
+
+// ErrNotOnWhatsApp is returned when a correctly formatted number isn't
+// registered on WhatsApp. It is never retried.
+var ErrNotOnWhatsApp = errors.New("phone number is not on WhatsApp")
+
+// ErrAlreadyInChat is returned when browser.skip_if_already_in_chat finds
+// the message among the chat's recent outgoing messages, so it isn't sent
+// again
+var ErrAlreadyInChat = errors.New("message is already in the chat")
+
+// ErrSkippedByOperator is returned when the operator skips a composed
+// message in -interactive mode. Nothing was sent and it is never retried.
+var ErrSkippedByOperator = errors.New("skipped by operator")
+
+// ErrChatNotFound is returned when searching for a chat (browser.open_chat_by:
+// search) finds no matching contact or group. It is never retried.
+var ErrChatNotFound = errors.New("no chat found for search term")
+
+// Selectors for quoting the last inbound message (browser.quote_last_inbound):
+// the arrow that opens a bubble's context menu and the menu's Reply item
+var (
+	quoteMenuArrowSelectors = []string{
+		`span[data-icon='down-context']`,
+		`span[data-icon='ic-chevron-down-menu']`,
+		`[aria-label='Context menu']`,
+	}
+	quoteReplySelectors = []string{
+		`//div[@role='application']//li[.//div[text()='Reply']]`,
+		`//li[@data-animate-dropdown-item]//div[text()='Reply']`,
+		`//div[@aria-label='Reply']`,
+	}
+)
+
+// invalidNumberDialogTexts are substrings of the dialog WhatsApp Web shows
+// when a chat can't be opened for a number
+var invalidNumberDialogTexts = []string{
+	"Phone number shared via url is invalid",
+	"isn't on WhatsApp",
+	"is not on WhatsApp",
+}
+
+// ErrImagePreviewMissing is returned when the image was attached but WhatsApp
+// never showed the media preview, even after re-attaching it once.
+var ErrImagePreviewMissing = errors.New("image preview did not appear after attaching image")
+
+// imagePreviewTimeout is how long to wait for the media preview after attaching
+const imagePreviewTimeout = 10 * time.Second
+
+// imagePreviewCloseTimeout is how long the media preview may take to close
+// after send was pressed before the send counts as unverified
+const imagePreviewCloseTimeout = 5 * time.Second
+
+// sendStrategy is the approach a single send attempt uses. With
+// retry.escalate enabled, each retry moves to a more aggressive strategy
+// instead of repeating the same one.
+type sendStrategy int
+
+const (
+	strategyKeyboard sendStrategy = iota // Type with keyboard simulation, DOM injection as fallback
+	strategyDOM                          // Go straight to DOM injection
+	strategyReload                       // Reload WhatsApp Web first, then DOM injection
 )
 
+func (s sendStrategy) String() string {
+	switch s {
+	case strategyKeyboard:
+		return "keyboard"
+	case strategyDOM:
+		return "dom-injection"
+	case strategyReload:
+		return "reload+dom-injection"
+	default:
+		return "unknown"
+	}
+}
+
+// strategyForAttempt returns the strategy for a zero-based attempt number
+func (c *WhatsAppClient) strategyForAttempt(attempt int) sendStrategy {
+	if !c.config.Retry.Escalate {
+		return strategyKeyboard
+	}
+	switch {
+	case attempt == 0:
+		return strategyKeyboard
+	case attempt == 1:
+		return strategyDOM
+	default:
+		return strategyReload
+	}
+}
+
+// SendOptions holds per-contact overrides for a single send
+type SendOptions struct {
+	TextOnly   bool           // Skip the configured image and send text only
+	Opener     string         // Sent first, only when the chat has no prior messages
+	ImagePath  string         // The attached file; empty uses files.image_path or files.video_path
+	Attachment AttachmentKind // How ImagePath is sent, from the attachment column; empty is an image
+	SearchTerm string         // Open the chat through the search box instead of the send URL
+
+	// Set from the contact's priority (rate_limiting.priorities)
+	ExtraDelay      time.Duration // Extra wait before this send
+	SuccessCriteria string        // Overrides browser.success_criteria
+
+	MaxRetries *int // From the contact's max_retries column, overrides retry.max_retries
+
+	SkipIfInChat  bool   // Don't send if the message is already in the chat (browser.skip_if_already_in_chat)
+	ImageRequired bool   // Fail instead of falling back to text when the image can't be sent
+	Group         bool   // The chat is a group found through SearchTerm, there is no number
+	Recipient     string // Contact name the chat header may show instead of the number (browser.verify_recipient)
+	SelfChat      bool   // Our own number, whose header shows the account name; not checked by verify_recipient
+	Review        bool   // Ask the operator on the terminal before sending the composed message (-interactive)
+	FollowUp      bool   // Type into the chat the last send left open instead of opening it (SendFollowUp)
+}
+
 type WhatsAppClient struct {
-	config      *Config
+	config      *automessage.Config
 	ctx         context.Context
 	cancel      context.CancelFunc
 	allocCancel context.CancelFunc
 	rateLimiter <-chan time.Time
+
+	// Consecutive sends that stayed on the pending clock, a soft-ban signal
+	consecutivePending int
+
+	// Recent sends that weren't delivered, a sign of recipients blocking us
+	deliveryHealth deliveryHealth
+
+	// Number of contacts sent to so far in this run, drives the delay ramp
+	sendCount int
+
+	// Screenshot of the composed message for the most recent send, for reports
+	lastPreviewScreenshot string
+
+	// Whether the most recent send delivered its image or attachment
+	lastAttachmentSent bool
+
+	// Per-phase send timings over the run
+	timings phaseStats
+
+	// Whether an ignored update prompt was already reported
+	updatePromptWarned bool
+
+	// Which fallback selectors matched over the run
+	selectors selectorStats
+
+	// Success criteria for the current send, browser.success_criteria unless
+	// the contact's priority overrides it
+	successCriteria string
+
+	// Set when the tab reports a crash; the browser is restarted before the
+	// next attempt, at most browser.max_reinit times per run
+	tabCrashed  atomic.Bool
+	reinitCount int
+
+	// Operator overrides of which errors are retried, from
+	// retry.retry_on_patterns and retry.abort_on_patterns
+	retryPatterns []*regexp.Regexp
+	abortPatterns []*regexp.Regexp
 }
 
-func NewWhatsAppClient(config *Config) *WhatsAppClient {
+func NewWhatsAppClient(config *automessage.Config) *WhatsAppClient {
 	client := &WhatsAppClient{
 		config: config,
 	}
 
+	// Validated by LoadConfig, so these can't fail here
+	client.retryPatterns, _ = automessage.CompilePatterns("retry.retry_on_patterns", config.Retry.RetryOnPatterns)
+	client.abortPatterns, _ = automessage.CompilePatterns("retry.abort_on_patterns", config.Retry.AbortOnPatterns)
+
 	// Setup rate limiter if enabled
 	if config.RateLimiting.Enabled && config.RateLimiting.MessagesPerSecond > 0 {
 		interval := time.Second / time.Duration(config.RateLimiting.MessagesPerSecond)
@@ -35,15 +212,28 @@ func NewWhatsAppClient(config *Config) *WhatsAppClient {
 }
 
 func (c *WhatsAppClient) Initialize() error {
-	Log("info", "Initializing browser automation...")
+	automessage.Log("info", "Initializing browser automation...")
 
 	// Check network connectivity
-	Log("debug", "Checking network connectivity to WhatsApp Web...")
-	if err := checkNetworkConnectivity(); err != nil {
-		Log("warn", fmt.Sprintf("Network connectivity check failed: %v", err))
-		Log("warn", "Proceeding anyway, but you may experience connection issues")
+	if c.config.Browser.SkipNetworkCheck {
+		automessage.Log("debug", "Skipping network connectivity check (browser.skip_network_check)")
 	} else {
-		Log("debug", "Network connectivity check passed")
+		automessage.Log("debug", "Checking network connectivity to WhatsApp Web...")
+		if err := checkNetworkConnectivity(c.config.Browser.ProxyServer); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Network connectivity check failed: %v", err))
+			automessage.Log("warn", "Proceeding anyway, but you may experience connection issues")
+		} else {
+			automessage.Log("debug", "Network connectivity check passed")
+		}
+	}
+
+	// Look for the browser only now, so runs that never open one (-dry-run,
+	// -render-only, -plan) work on machines without it
+	if c.config.Browser.ChromePath == "" {
+		c.config.Browser.ChromePath = automessage.FindChromePath(c.config.Browser.BrowserType)
+		if c.config.Browser.ChromePath == "" && c.config.Browser.BrowserType != "" {
+			return fmt.Errorf("could not find %s, set browser.chrome_path to its executable", c.config.Browser.BrowserType)
+		}
 	}
 
 	// Validate Chrome path if specified
@@ -54,15 +244,20 @@ func (c *WhatsAppClient) Initialize() error {
 			}
 			return fmt.Errorf("cannot access Chrome executable at %s: %w", c.config.Browser.ChromePath, err)
 		}
-		Log("info", fmt.Sprintf("Using Chrome at: %s", c.config.Browser.ChromePath))
+		browserName := c.config.Browser.BrowserType
+		if browserName == "" {
+			browserName = "Chromium-based browser"
+		}
+		automessage.Log("info", fmt.Sprintf("Using %s at: %s", browserName, c.config.Browser.ChromePath))
 	} else {
-		Log("info", "No Chrome path specified, using chromedp defaults")
+		automessage.Log("info", "No Chrome path specified, using chromedp defaults")
 	}
 
 	// Ensure user data directory exists with proper permissions
 	if err := ensureUserDataDir(c.config.Browser.UserDataDir); err != nil {
 		return fmt.Errorf("failed to create user data directory: %w", err)
 	}
+	markProfileExitedCleanly(c.config.Browser.UserDataDir)
 
 	// Setup Chrome options
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
@@ -73,9 +268,18 @@ func (c *WhatsAppClient) Initialize() error {
 		chromedp.Flag("excludeSwitches", "enable-automation"),
 		chromedp.Flag("disable-extensions", false),
 		chromedp.Flag("disable-prompt-on-repost", true),
+		// No "Restore pages?" bubble after a crash or killed run
+		chromedp.Flag("hide-crash-restore-bubble", true),
+		chromedp.Flag("disable-session-crashed-bubble", true),
 		chromedp.WindowSize(1200, 800),
 	)
 
+	// Route browser traffic through the configured proxy
+	if c.config.Browser.ProxyServer != "" {
+		opts = append(opts, chromedp.ProxyServer(c.config.Browser.ProxyServer))
+		automessage.Log("info", fmt.Sprintf("Using proxy server: %s", c.config.Browser.ProxyServer))
+	}
+
 	// Add explicit Chrome path if configured or detected
 	if c.config.Browser.ChromePath != "" {
 		opts = append(opts, chromedp.ExecPath(c.config.Browser.ChromePath))
@@ -85,14 +289,34 @@ func (c *WhatsAppClient) Initialize() error {
 	c.allocCancel = allocCancel
 	c.ctx, c.cancel = chromedp.NewContext(allocCtx)
 
+	// Notice renderer crashes (e.g. out of memory) so the run can recover
+	c.tabCrashed.Store(false)
+	chromedp.ListenTarget(c.ctx, func(ev interface{}) {
+		if _, ok := ev.(*inspector.EventTargetCrashed); ok {
+			automessage.Log("error", "Browser tab crashed")
+			c.tabCrashed.Store(true)
+		}
+	})
+
+	// Forward the browser console into our log when debugging
+	if c.config.Browser.ConsoleLog {
+		if !automessage.DebugEnabled() {
+			automessage.Log("warn", "Browser console forwarding requires logging level 'debug', ignoring")
+		} else if err := c.enableConsoleForwarding(); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Failed to enable browser console forwarding: %v", err))
+		} else {
+			automessage.Log("debug", "Browser console forwarding enabled")
+		}
+	}
+
 	// Navigate to WhatsApp Web
-	Log("info", "Opening WhatsApp Web...")
-	Log("debug", "Starting Chrome browser process...")
+	automessage.Log("info", "Opening WhatsApp Web...")
+	automessage.Log("debug", "Starting Chrome browser process...")
 	err := chromedp.Run(c.ctx,
-		chromedp.Navigate("https://web.whatsapp.com"),
+		chromedp.Navigate(c.config.Browser.WebURL),
 	)
 	if err != nil {
-		Log("error", fmt.Sprintf("Chrome startup or navigation failed: %v", err))
+		automessage.Log("error", fmt.Sprintf("Chrome startup or navigation failed: %v", err))
 		// Provide helpful error messages based on common issues
 		if strings.Contains(err.Error(), "chrome failed to start") {
 			return fmt.Errorf("Chrome failed to start. Try:\n1. Close all Chrome windows\n2. Delete the chrome-data folder\n3. Check Chrome path in config.yaml\nError: %w", err)
@@ -102,13 +326,44 @@ func (c *WhatsAppClient) Initialize() error {
 		}
 		return fmt.Errorf("failed to navigate to WhatsApp Web: %w", err)
 	}
-	Log("info", "Chrome started and navigated to WhatsApp Web")
+	automessage.Log("info", "Chrome started and navigated to WhatsApp Web")
 
 	// Wait for login (either QR code scan or existing session)
-	Log("info", "Waiting for WhatsApp Web to load...")
-	Log("info", fmt.Sprintf("If you see a QR code, please scan it within %d seconds", c.config.Browser.QRTimeoutSeconds))
+	automessage.Log("info", "Waiting for WhatsApp Web to load...")
+	automessage.Log("info", fmt.Sprintf("If you see a QR code, please scan it within %d seconds", c.config.Browser.QRTimeoutSeconds))
+
+	// Check if already logged in or wait for QR scan, offering to extend the
+	// wait on a terminal while the QR code is still showing
+	for extensions := 0; ; extensions++ {
+		err = c.waitForLogin()
+		if err == nil {
+			break
+		}
+		if !strings.Contains(err.Error(), "context deadline exceeded") {
+			return fmt.Errorf("failed to load WhatsApp Web: %w", err)
+		}
+
+		if extensions >= c.config.Browser.QRMaxExtensions || !stdinIsTerminal() || !c.qrCodeVisible() {
+			return fmt.Errorf("timeout waiting for WhatsApp Web login. Please scan the QR code within %d seconds", c.config.Browser.QRTimeoutSeconds)
+		}
+		if !promptYesNo(fmt.Sprintf("QR not scanned yet — wait another %ds?", c.config.Browser.QRTimeoutSeconds), true) {
+			return fmt.Errorf("WhatsApp Web login cancelled: QR code not scanned")
+		}
+		automessage.Log("info", fmt.Sprintf("Extending the QR wait by %d seconds (%d/%d)",
+			c.config.Browser.QRTimeoutSeconds, extensions+1, c.config.Browser.QRMaxExtensions))
+	}
 
-	// Check if already logged in or wait for QR scan
+	automessage.Log("info", "WhatsApp Web loaded successfully!")
+
+	// Wait a bit for the page to fully stabilize
+	time.Sleep(3 * time.Second)
+
+	return nil
+}
+
+// waitForLogin waits up to browser.qr_timeout_seconds for the chat list,
+// logging progress every 10 seconds
+func (c *WhatsAppClient) waitForLogin() error {
 	timeoutCtx, timeoutCancel := context.WithTimeout(c.ctx, time.Duration(c.config.Browser.QRTimeoutSeconds)*time.Second)
 	defer timeoutCancel()
 
@@ -125,38 +380,91 @@ func (c *WhatsAppClient) Initialize() error {
 	defer ticker.Stop()
 	startTime := time.Now()
 
-waitLoop:
 	for {
 		select {
-		case err = <-done:
-			break waitLoop
+		case err := <-done:
+			return err
 		case <-ticker.C:
 			elapsed := time.Since(startTime).Seconds()
 			remaining := float64(c.config.Browser.QRTimeoutSeconds) - elapsed
 			if remaining > 0 {
-				Log("info", fmt.Sprintf("Still waiting for WhatsApp Web to load... (%.0f seconds remaining)", remaining))
+				automessage.Log("info", fmt.Sprintf("Still waiting for WhatsApp Web to load... (%.0f seconds remaining)", remaining))
 			}
 		}
 	}
+}
+
+// qrCodeSelector matches the login QR code on the WhatsApp Web landing page
+const qrCodeSelector = `div[data-ref] canvas, canvas[aria-label*="QR" i], canvas[aria-label*="Scan" i]`
+
+// qrCodeVisible reports whether the login QR code is still on screen
+func (c *WhatsAppClient) qrCodeVisible() bool {
+	var visible bool
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`document.querySelector(%q) !== null`, qrCodeSelector), &visible),
+	)
+	return visible
+}
+
+// browserGone reports whether the tab crashed or the browser process died
+func (c *WhatsAppClient) browserGone() bool {
+	return c.tabCrashed.Load() || (c.ctx != nil && c.ctx.Err() != nil)
+}
+
+// restartBrowser tears down the dead browser and initializes a new one with
+// the same user data directory, so the saved session is reused without a QR
+// scan
+func (c *WhatsAppClient) restartBrowser() error {
+	if c.reinitCount >= c.config.Browser.MaxReinit {
+		return fmt.Errorf("browser already restarted %d times (browser.max_reinit)", c.reinitCount)
+	}
+	c.reinitCount++
 
-	if err != nil {
-		if strings.Contains(err.Error(), "context deadline exceeded") {
-			return fmt.Errorf("timeout waiting for WhatsApp Web login. Please scan the QR code within %d seconds", c.config.Browser.QRTimeoutSeconds)
-		}
-		return fmt.Errorf("failed to load WhatsApp Web: %w", err)
+	automessage.Log("warn", fmt.Sprintf("Browser is gone, restarting it (%d/%d)...", c.reinitCount, c.config.Browser.MaxReinit))
+	c.Close()
+	time.Sleep(2 * time.Second)
+
+	if err := c.Initialize(); err != nil {
+		return err
+	}
+	automessage.Log("info", "✓ Browser restarted, resuming run")
+	return nil
+}
+
+// resetPage clears the state WhatsApp Web builds up over many chat
+// navigations, per browser.reload_mode: "blank" leaves the page for
+// about:blank and loads WhatsApp Web again, "restart" starts a new browser.
+// The saved session is reused either way, so no QR scan is needed.
+func (c *WhatsAppClient) resetPage() error {
+	automessage.Log("info", fmt.Sprintf("Resetting WhatsApp Web after %d sends (browser.reload_every_n, %s)",
+		c.sendCount-1, c.config.Browser.ReloadMode))
+
+	if c.config.Browser.ReloadMode == "restart" {
+		c.Close()
+		time.Sleep(2 * time.Second)
+		return c.Initialize()
 	}
 
-	Log("info", "WhatsApp Web loaded successfully!")
+	err := chromedp.Run(c.ctx,
+		chromedp.Navigate("about:blank"),
+		chromedp.Sleep(time.Second),
+		chromedp.Navigate(c.config.Browser.WebURL),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reload WhatsApp Web: %w", err)
+	}
+	if err := c.waitForLogin(); err != nil {
+		return fmt.Errorf("WhatsApp Web did not load after reset: %w", err)
+	}
 
-	// Wait a bit for the page to fully stabilize
+	// Same settle as after the initial login
 	time.Sleep(3 * time.Second)
-
 	return nil
 }
 
 func (c *WhatsAppClient) Close() {
 	if c.cancel != nil {
-		Log("info", "Closing browser...")
+		automessage.Log("info", "Closing browser...")
 		c.cancel()
 	}
 	if c.allocCancel != nil {
@@ -164,7 +472,60 @@ func (c *WhatsAppClient) Close() {
 	}
 }
 
-func (c *WhatsAppClient) SendMessage(phoneNumber, message string) error {
+func (c *WhatsAppClient) SendMessage(phoneNumber, message string, opts SendOptions) error {
+	// Back off if recent messages never left the pending state
+	c.pauseIfSoftBanned()
+	c.pauseIfUnhealthy()
+	c.lastPreviewScreenshot = ""
+	c.lastAttachmentSent = false
+
+	// A pending WhatsApp Web update leaves the page stale until reloaded
+	if err := c.handleUpdatePrompt(); err != nil {
+		return err
+	}
+
+	// Start slow and speed up over the first messages of the run
+	if delay := c.rampDelay(c.sendCount); delay > 0 {
+		automessage.Log("debug", fmt.Sprintf("Ramp delay before message %d: %v", c.sendCount+1, delay))
+		time.Sleep(delay)
+	}
+	c.sendCount++
+
+	// Long runs make WhatsApp Web sluggish, start over with a fresh page now and then
+	if n := c.config.Browser.ReloadEveryN; n > 0 && c.sendCount > 1 && (c.sendCount-1)%n == 0 {
+		if err := c.resetPage(); err != nil {
+			return fmt.Errorf("failed to reset WhatsApp Web after %d sends: %w", c.sendCount-1, err)
+		}
+	}
+
+	if opts.ExtraDelay > 0 {
+		automessage.Log("debug", fmt.Sprintf("Priority delay before sending to %s: %v", phoneNumber, opts.ExtraDelay))
+		time.Sleep(opts.ExtraDelay)
+	}
+
+	return c.send(phoneNumber, message, opts)
+}
+
+// SendFollowUp sends another part of a contact's message as text into the
+// chat the last send left open. Unlike SendMessage it doesn't open the chat
+// again, wait out the ramp, reload the page or pause for a soft ban or poor
+// delivery, and it doesn't count as a new send: the parts of one message
+// belong together. Only rate_limiting.messages_per_second paces it.
+func (c *WhatsAppClient) SendFollowUp(phoneNumber, message string, opts SendOptions) error {
+	opts.TextOnly = true
+	opts.FollowUp = true
+	opts.Opener = ""
+	return c.send(phoneNumber, message, opts)
+}
+
+// send makes the attempts of one send, retrying failures that may be
+// temporary with backoff
+func (c *WhatsAppClient) send(phoneNumber, message string, opts SendOptions) error {
+	c.successCriteria = c.config.Browser.SuccessCriteria
+	if opts.SuccessCriteria != "" {
+		c.successCriteria = opts.SuccessCriteria
+	}
+
 	// Apply rate limiting
 	if c.rateLimiter != nil {
 		<-c.rateLimiter
@@ -174,11 +535,23 @@ func (c *WhatsAppClient) SendMessage(phoneNumber, message string) error {
 	retryDelay := time.Duration(c.config.Retry.InitialDelaySeconds) * time.Second
 	maxDelay := time.Duration(c.config.Retry.MaxDelaySeconds) * time.Second
 
-	for attempt := 0; attempt <= c.config.Retry.MaxRetries; attempt++ {
+	// Set when WhatsApp throttled the last attempt, replaces the next delay
+	var throttleDelay time.Duration
+
+	maxRetries := c.config.Retry.MaxRetries
+	if opts.MaxRetries != nil {
+		maxRetries = *opts.MaxRetries
+	}
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
-			Log("info", fmt.Sprintf("Retry attempt %d/%d for %s after %v",
-				attempt, c.config.Retry.MaxRetries, phoneNumber, retryDelay))
-			time.Sleep(retryDelay)
+			delay := retryDelay
+			if throttleDelay > 0 {
+				delay, throttleDelay = throttleDelay, 0
+			}
+			automessage.Log("info", fmt.Sprintf("Retry attempt %d/%d for %s after %v",
+				attempt, maxRetries, phoneNumber, delay))
+			time.Sleep(delay)
 
 			// Exponential backoff
 			retryDelay = time.Duration(float64(retryDelay) * c.config.Retry.BackoffMultiplier)
@@ -187,114 +560,178 @@ func (c *WhatsAppClient) SendMessage(phoneNumber, message string) error {
 			}
 		}
 
-		err := c.sendMessageAttempt(phoneNumber, message)
-		if err == nil {
-			return nil // Success
+		// The failed attempt may have left the chat, a retry opens it again
+		if attempt > 0 {
+			opts.FollowUp = false
 		}
 
-		lastErr = err
-		Log("warn", fmt.Sprintf("Failed to send message to %s: %v", phoneNumber, err))
-	}
-
-	return fmt.Errorf("failed after %d retries: %w", c.config.Retry.MaxRetries, lastErr)
-}
+		strategy := c.strategyForAttempt(attempt)
+		if c.config.Retry.Escalate {
+			automessage.Log("info", fmt.Sprintf("Attempt %d for %s using %s strategy", attempt+1, phoneNumber, strategy))
+		}
 
-func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string) error {
-	// Clean phone number (remove + and spaces)
-	cleanNumber := strings.ReplaceAll(strings.ReplaceAll(phoneNumber, "+", ""), " ", "")
+		err := c.sendMessageAttempt(phoneNumber, message, opts, strategy)
+		if err == nil {
+			return nil // Success
+		}
+		if errors.Is(err, ErrSendUnverified) {
+			return err // Never retry, the message may already be delivered
+		}
+		if errors.Is(err, ErrAlreadyInChat) {
+			return err // Sent before, possibly by an earlier attempt
+		}
+		if errors.Is(err, ErrSkippedByOperator) {
+			return err
+		}
 
-	// Use WhatsApp Web direct URL to open chat
-	chatURL := fmt.Sprintf("https://web.whatsapp.com/send?phone=%s", cleanNumber)
+		// Operator patterns come before the built-in rules, abort first
+		if re := automessage.MatchingPattern(c.abortPatterns, err); re != nil {
+			automessage.Log("warn", fmt.Sprintf("Not retrying %s: error matches retry.abort_on_patterns %q", phoneNumber, re))
+			return err
+		}
+		forceRetry := automessage.MatchingPattern(c.retryPatterns, err) != nil
+		if forceRetry {
+			automessage.Log("debug", fmt.Sprintf("Error for %s matches retry.retry_on_patterns, retrying", phoneNumber))
+		} else {
+			if errors.Is(err, ErrInvalidFormat) || errors.Is(err, ErrNotOnWhatsApp) {
+				return err // Retrying can't fix the number
+			}
+			if errors.Is(err, ErrChatNotFound) {
+				return err // Retrying won't make the contact appear
+			}
+			if errors.Is(err, ErrWrongRecipient) {
+				return err // Never risk typing into someone else's chat
+			}
+		}
 
-	Log("debug", fmt.Sprintf("Opening chat for %s", phoneNumber))
+		lastErr = err
+		automessage.Log("warn", fmt.Sprintf("Failed to send message to %s: %v", phoneNumber, err))
 
-	// Send image with caption if configured
-	if c.config.Files.ImagePath != "" {
-		if err := c.sendImageWithCaption(phoneNumber, cleanNumber, chatURL, message); err != nil {
-			Log("warn", fmt.Sprintf("Failed to send image to %s: %v", phoneNumber, err))
-			Log("warn", "Continuing with text message only...")
-		} else {
-			Log("info", "Image with caption sent successfully!")
-			return nil // Image was sent with caption, we're done
+		var throttled *ThrottledError
+		if errors.As(err, &throttled) {
+			throttleDelay = c.throttleBackoff(throttled, retryDelay)
+			automessage.Log("warn", fmt.Sprintf("WhatsApp is throttling sends, waiting %v before the next attempt", throttleDelay))
 		}
-	}
 
-	// Disable beforeunload event to prevent "Leave site?" dialog
-	err := chromedp.Run(c.ctx,
-		chromedp.Evaluate(`window.onbeforeunload = null;`, nil),
-	)
-	if err != nil {
-		Log("warn", fmt.Sprintf("Failed to disable beforeunload: %v", err))
+		// Every later chromedp call would fail too, bring the browser back first
+		if c.browserGone() {
+			if err := c.restartBrowser(); err != nil {
+				return fmt.Errorf("browser crashed and could not be restarted: %w", err)
+			}
+		}
 	}
 
-	// Navigate to chat URL
-	err = chromedp.Run(c.ctx,
-		chromedp.Navigate(chatURL),
-		chromedp.Sleep(3*time.Second), // Wait for navigation
-	)
-	if err != nil {
-		return fmt.Errorf("failed to navigate to chat: %w", err)
-	}
+	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+}
 
-	// Wait for chat to fully load and "Starting chat" dialog to disappear
-	Log("debug", "Waiting for chat to fully load...")
+// buildChatURL returns the WhatsApp Web URL that opens a chat with the number
+func buildChatURL(sendURLBase, phoneNumber string) string {
+	return fmt.Sprintf("%s?phone=%s", sendURLBase, automessage.CleanPhoneNumber(phoneNumber))
+}
 
-	// Clean phone number for screenshot filename
-	cleanNumberForFile := strings.ReplaceAll(cleanNumber, "+", "")
+func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string, opts SendOptions, strategy sendStrategy) error {
+	// Digits only, as used in the chat URL and screenshot names
+	cleanNumber := automessage.CleanPhoneNumber(phoneNumber)
 
-	// Explicitly wait for "Starting chat" spinner/dialog to disappear
-	Log("info", "Waiting for 'Starting chat' dialog to disappear...")
-	maxStartWait := 15 * time.Second
-	startWaitBegin := time.Now()
-	dialogGone := false
+	// Malformed numbers can't open a chat, don't bother the browser. Chats
+	// opened by searching for a name don't use the number at all.
+	if !opts.Group && (opts.SearchTerm == "" || c.config.Browser.SearchField == "phone") {
+		if err := automessage.ValidatePhoneNumber(phoneNumber); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+		}
+	}
 
-	for time.Since(startWaitBegin) < maxStartWait {
-		var spinnerVisible bool
-		err = chromedp.Run(c.ctx,
-			chromedp.Evaluate(`
-				(function() {
-					// Check for "Starting chat" text or spinner
-					const startingText = Array.from(document.querySelectorAll('div, span')).find(el =>
-						el.textContent.includes('Starting chat') || el.textContent.includes('starting chat')
-					);
-					if (startingText && startingText.offsetParent !== null) return true;
+	// Use WhatsApp Web direct URL to open chat
+	chatURL := buildChatURL(c.config.Browser.SendURLBase, phoneNumber)
 
-					// Check for loading spinners
-					const spinner = document.querySelector('div[role="progressbar"]');
-					if (spinner && spinner.offsetParent !== null) return true;
+	timing := newSendTiming()
+	defer func() {
+		if len(timing.phases) > 0 {
+			automessage.Log("debug", fmt.Sprintf("Send timing for %s: %s", phoneNumber, timing))
+			c.timings.add(timing)
+		}
+	}()
 
-					return false;
-				})()
-			`, &spinnerVisible),
-		)
+	automessage.Log("debug", fmt.Sprintf("Opening chat for %s", phoneNumber))
 
-		if err != nil || !spinnerVisible {
-			dialogGone = true
-			Log("info", "✓ 'Starting chat' dialog is gone")
-			break
+	// Start from a freshly loaded WhatsApp Web when escalated
+	if strategy == strategyReload {
+		if err := c.reloadWhatsApp(); err != nil {
+			return err
 		}
+	}
 
-		Log("debug", fmt.Sprintf("'Starting chat' dialog still visible, waiting... (%v elapsed)", time.Since(startWaitBegin).Round(time.Second)))
-		time.Sleep(500 * time.Millisecond)
+	// Send image or video with caption if configured and the contact didn't
+	// opt out
+	imagePath := c.config.Files.ImagePath
+	if opts.ImagePath != "" {
+		imagePath = opts.ImagePath
+	} else if c.config.Files.VideoPath != "" {
+		imagePath = c.config.Files.VideoPath
+		opts.Attachment = AttachmentVideo
+	}
+	if imagePath != "" && !opts.TextOnly {
+		var err error
+		if opts.Attachment == AttachmentVideo {
+			err = c.sendVideoWithCaption(phoneNumber, cleanNumber, chatURL, imagePath, message, opts)
+		} else {
+			err = c.sendImageWithCaption(phoneNumber, cleanNumber, chatURL, imagePath, message, opts)
+		}
+		timing.phase("image_send")
+		// Once send was pressed the image and caption may be out, so the
+		// caption must not go again as text
+		if errors.Is(err, ErrSendUnverified) || errors.Is(err, ErrAlreadyInChat) || errors.Is(err, ErrWrongRecipient) || errors.Is(err, ErrSkippedByOperator) {
+			return err
+		}
+		if err != nil {
+			automessage.Log("warn", fmt.Sprintf("Failed to send image to %s: %v", phoneNumber, err))
+			if opts.ImageRequired {
+				return fmt.Errorf("failed to send image %s: %w", filepath.Base(imagePath), err)
+			}
+			if strings.TrimSpace(message) == "" {
+				return fmt.Errorf("failed to send image without caption: %w", err)
+			}
+			automessage.Log("warn", "Continuing with text message only...")
+		} else {
+			automessage.Log("info", "Image with caption sent successfully!")
+			c.lastAttachmentSent = !c.config.Sandbox()
+			return nil // Image was sent with caption, we're done
+		}
 	}
 
-	if !dialogGone {
-		Log("warn", "Timed out waiting for 'Starting chat' dialog to disappear, proceeding anyway...")
+	// Follow-up parts go into the chat the previous part was sent in
+	if !opts.FollowUp {
+		if err := c.openChatForSend(phoneNumber, cleanNumber, chatURL, opts, timing); err != nil {
+			return err
+		}
 	}
+	cleanNumberForFile := cleanNumber
+	var err error
 
-	// Additional wait to ensure UI is stable
-	time.Sleep(1 * time.Second)
-	c.takeScreenshot(fmt.Sprintf("text_01_chat_opened_%s.png", cleanNumberForFile))
+	if opts.SkipIfInChat && c.alreadyInChat(message) {
+		automessage.Log("info", fmt.Sprintf("Message for %s is already in the chat, not sending it again", phoneNumber))
+		return ErrAlreadyInChat
+	}
 
 	// Count existing messages before we send (to verify new message was sent)
-	var messageCountBefore int
-	chromedp.Run(c.ctx,
-		chromedp.Evaluate(`document.querySelectorAll('div[data-pre-plain-text]').length`, &messageCountBefore),
-	)
-	Log("debug", fmt.Sprintf("Message count before sending: %d", messageCountBefore))
+	messageCountBefore := c.messageCounts()
+	automessage.Log("debug", fmt.Sprintf("Message count before sending: %v", messageCountBefore))
+
+	// Brand-new chats get the opener first, then the main message
+	if isEmptyChat(messageCountBefore) && opts.Opener != "" {
+		automessage.Log("info", fmt.Sprintf("New chat with %s - sending opener first", phoneNumber))
+		if err := c.sendMessageAttempt(phoneNumber, opts.Opener, SendOptions{TextOnly: true, SearchTerm: opts.SearchTerm, Group: opts.Group, Recipient: opts.Recipient, SelfChat: opts.SelfChat, Review: opts.Review}, strategy); err != nil {
+			return fmt.Errorf("failed to send opener: %w", err)
+		}
+		automessage.Log("info", "✓ Opener sent, continuing with main message")
+		timing.phase("opener")
+
+		messageCountBefore = c.messageCounts()
+		automessage.Log("debug", fmt.Sprintf("Message count after opener: %v", messageCountBefore))
+	}
 
 	// Wait for the message input box to be visible
-	Log("debug", "Waiting for message input box...")
+	automessage.Log("debug", "Waiting for message input box...")
 
 	// Try different possible selectors for the message input box
 	inputSelectors := []string{
@@ -307,32 +744,34 @@ func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string) error {
 	var inputFound bool
 	var usedSelector string
 
-	for _, selector := range inputSelectors {
+	for i, selector := range inputSelectors {
 		err = chromedp.Run(c.ctx,
 			chromedp.WaitVisible(selector, chromedp.BySearch),
 		)
 		if err == nil {
 			inputFound = true
 			usedSelector = selector
-			Log("debug", fmt.Sprintf("Found message input using selector: %s", selector))
+			c.selectors.record("input", i)
+			automessage.Log("debug", fmt.Sprintf("Found message input using selector: %s", selector))
 			break
 		}
 	}
 
 	if !inputFound {
-		// Check if number is invalid
-		var invalidText string
-		chromedp.Run(c.ctx,
-			chromedp.Text(`//div[contains(text(), 'Phone number')]`, &invalidText, chromedp.BySearch),
-		)
-		if invalidText != "" {
-			return fmt.Errorf("invalid phone number: %s", phoneNumber)
+		// Check if WhatsApp rejected the number
+		if err := c.detectChatError(phoneNumber); err != nil {
+			c.takeScreenshot(fmt.Sprintf("text_01_chat_rejected_%s.png", cleanNumberForFile))
+			return err
+		}
+		if throttled := c.detectThrottle(); throttled != nil {
+			return throttled
 		}
 
 		return fmt.Errorf("could not find message input box (chat may not have loaded)")
 	}
+	timing.phase("input_find")
 
-	Log("debug", "Preparing to paste message...")
+	automessage.Log("debug", "Preparing to paste message...")
 
 	// Click the input box to focus it
 	err = chromedp.Run(c.ctx,
@@ -343,16 +782,22 @@ func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string) error {
 		return fmt.Errorf("failed to click message input: %w", err)
 	}
 
-	// Clear any existing text by selecting all and deleting
-	// Use Cmd+A on Mac, Ctrl+A on other systems
-	err = chromedp.Run(c.ctx,
-		chromedp.KeyEvent("a", chromedp.KeyModifiers(2)), // 2 = Cmd/Ctrl modifier
-		chromedp.Sleep(100*time.Millisecond),
-		chromedp.KeyEvent("\b"),
-		chromedp.Sleep(300*time.Millisecond),
-	)
-	if err != nil {
-		Log("warn", fmt.Sprintf("Failed to clear existing text: %v", err))
+	// An overlay can swallow the click, leaving our keystrokes with nowhere to go
+	if err := c.ensureInputFocused(usedSelector); err != nil {
+		c.takeScreenshot(fmt.Sprintf("text_02_input_not_focused_%s.png", cleanNumberForFile))
+		return err
+	}
+
+	// Clear any existing text so it doesn't get prepended to our message
+	if err := c.clearInput(); err != nil {
+		automessage.Log("warn", fmt.Sprintf("Failed to clear existing text: %v", err))
+	}
+
+	// Send as a quoted reply to the contact's last message when configured
+	if c.config.Browser.QuoteLastInbound && c.quoteLastInbound() {
+		if err := c.ensureInputFocused(usedSelector); err != nil {
+			return err
+		}
 	}
 
 	// Normalize line endings - Windows uses \r\n, Unix uses \n
@@ -361,81 +806,85 @@ func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string) error {
 	normalizedMessage = strings.ReplaceAll(normalizedMessage, "\r", "\n")
 
 	// Method 1: Type message with proper newline handling (Shift+Enter for newlines)
-	Log("info", "Method 1: Typing message with keyboard simulation...")
 	var textPasted bool
+	if strategy == strategyKeyboard {
+		automessage.Log("info", "Method 1: Typing message with keyboard simulation...")
 
-	// Split message by newlines
-	lines := strings.Split(normalizedMessage, "\n")
+		// Split message by newlines
+		lines := strings.Split(normalizedMessage, "\n")
 
-	// Remove consecutive empty lines (which cause double spacing)
-	var filteredLines []string
-	lastWasEmpty := false
-	for _, line := range lines {
-		if line == "" {
-			if !lastWasEmpty {
+		// Remove consecutive empty lines (which cause double spacing)
+		var filteredLines []string
+		lastWasEmpty := false
+		for _, line := range lines {
+			if line == "" {
+				if !lastWasEmpty {
+					filteredLines = append(filteredLines, line)
+				}
+				lastWasEmpty = true
+			} else {
 				filteredLines = append(filteredLines, line)
+				lastWasEmpty = false
 			}
-			lastWasEmpty = true
-		} else {
-			filteredLines = append(filteredLines, line)
-			lastWasEmpty = false
 		}
-	}
-	lines = filteredLines
+		lines = filteredLines
 
-	// Type each line with Shift+Enter between them
-	for i, line := range lines {
-		if i > 0 {
-			// Send Shift+Enter for newline (Enter alone sends the message in WhatsApp)
-			err = chromedp.Run(c.ctx,
-				chromedp.KeyEvent("\r", chromedp.KeyModifiers(8)),
-				chromedp.Sleep(50*time.Millisecond),
-			)
-			if err != nil {
-				Log("warn", fmt.Sprintf("Failed to send Shift+Enter: %v", err))
-				break
+		// Type each line with Shift+Enter between them
+		for i, line := range lines {
+			if i > 0 {
+				// Send Shift+Enter for newline (Enter alone sends the message in WhatsApp)
+				err = chromedp.Run(c.ctx,
+					chromedp.KeyEvent("\r", chromedp.KeyModifiers(8)),
+					chromedp.Sleep(50*time.Millisecond),
+				)
+				if err != nil {
+					automessage.Log("warn", fmt.Sprintf("Failed to send Shift+Enter: %v", err))
+					break
+				}
+			}
+
+			// Type this line (even if empty, to maintain spacing)
+			if line != "" {
+				err = chromedp.Run(c.ctx,
+					chromedp.SendKeys(usedSelector, line, chromedp.BySearch, chromedp.NodeNotVisible),
+					chromedp.Sleep(50*time.Millisecond),
+				)
+				if err != nil {
+					automessage.Log("warn", fmt.Sprintf("Failed to type line: %v", err))
+					break
+				}
 			}
 		}
 
-		// Type this line (even if empty, to maintain spacing)
-		if line != "" {
-			err = chromedp.Run(c.ctx,
-				chromedp.SendKeys(usedSelector, line, chromedp.BySearch, chromedp.NodeNotVisible),
-				chromedp.Sleep(50*time.Millisecond),
+		if err != nil {
+			automessage.Log("warn", fmt.Sprintf("Keyboard simulation failed: %v, trying advanced DOM method", err))
+		} else {
+			// Verify that text was typed
+			time.Sleep(300 * time.Millisecond)
+			var inputText string
+			chromedp.Run(c.ctx,
+				chromedp.Evaluate(`
+					const input = document.querySelector('div[contenteditable="true"][data-tab="10"]') ||
+					              document.querySelector('div[contenteditable="true"][role="textbox"]');
+					if (!input) { '' }
+					else { input.innerText || input.textContent || input.innerHTML?.replace(/<[^>]*>/g, '') || '' }
+				`, &inputText),
 			)
-			if err != nil {
-				Log("warn", fmt.Sprintf("Failed to type line: %v", err))
-				break
+			inputText = strings.TrimSpace(inputText)
+			if len(inputText) > 0 {
+				textPasted = true
+				automessage.Log("info", fmt.Sprintf("✓ Keyboard typing successful (%d characters typed)", len(inputText)))
+			} else {
+				automessage.Log("warn", "Typing reported success but input is empty, trying advanced method...")
 			}
 		}
-	}
-
-	if err != nil {
-		Log("warn", fmt.Sprintf("Keyboard simulation failed: %v, trying advanced DOM method", err))
 	} else {
-		// Verify that text was typed
-		time.Sleep(300 * time.Millisecond)
-		var inputText string
-		chromedp.Run(c.ctx,
-			chromedp.Evaluate(`
-				const input = document.querySelector('div[contenteditable="true"][data-tab="10"]') ||
-				              document.querySelector('div[contenteditable="true"][role="textbox"]');
-				if (!input) { '' }
-				else { input.innerText || input.textContent || input.innerHTML?.replace(/<[^>]*>/g, '') || '' }
-			`, &inputText),
-		)
-		inputText = strings.TrimSpace(inputText)
-		if len(inputText) > 0 {
-			textPasted = true
-			Log("info", fmt.Sprintf("✓ Keyboard typing successful (%d characters typed)", len(inputText)))
-		} else {
-			Log("warn", "Typing reported success but input is empty, trying advanced method...")
-		}
+		automessage.Log("info", fmt.Sprintf("Skipping keyboard typing (%s strategy)", strategy))
 	}
 
 	// Method 2: Advanced DOM manipulation with proper WhatsApp structure
 	if !textPasted {
-		Log("info", "Method 2: Trying advanced DOM manipulation with WhatsApp structure...")
+		automessage.Log("info", "Method 2: Trying advanced DOM manipulation with WhatsApp structure...")
 
 		// Split message into lines for proper paragraph structure
 		lines := strings.Split(normalizedMessage, "\n")
@@ -492,16 +941,18 @@ func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string) error {
 
 		if err != nil || !advancedSuccess {
 			c.takeScreenshot(fmt.Sprintf("text_02_all_methods_failed_%s.png", cleanNumberForFile))
-			Log("error", "All text input methods failed!")
+			automessage.Log("error", "All text input methods failed!")
 			return fmt.Errorf("failed to input message using all available methods")
 		}
 
-		Log("info", "✓ Advanced DOM manipulation successful")
+		automessage.Log("info", "✓ Advanced DOM manipulation successful")
 		textPasted = true
 	}
 
+	// Let WhatsApp catch up with the typed text before reading it back
+	c.waitForInputText(normalizedMessage)
+
 	// Final verification - try multiple properties to read the input
-	time.Sleep(300 * time.Millisecond)
 	var finalInputText string
 	chromedp.Run(c.ctx,
 		chromedp.Evaluate(`
@@ -517,102 +968,253 @@ func (c *WhatsAppClient) sendMessageAttempt(phoneNumber, message string) error {
 
 	if len(finalInputText) == 0 {
 		c.takeScreenshot(fmt.Sprintf("text_02_input_verification_failed_%s.png", cleanNumberForFile))
-		Log("error", "Final verification: input is still empty!")
+		automessage.Log("error", "Final verification: input is still empty!")
 		return fmt.Errorf("text input failed - input box is empty after all methods")
 	}
 
-	Log("info", fmt.Sprintf("✓ Final verification: %d characters in input box", len(finalInputText)))
-	c.takeScreenshot(fmt.Sprintf("text_02_text_ready_%s.png", cleanNumberForFile))
+	automessage.Log("info", fmt.Sprintf("✓ Final verification: %d characters in input box", len(finalInputText)))
+	c.lastPreviewScreenshot = c.takeScreenshot(fmt.Sprintf("text_02_text_ready_%s.png", cleanNumberForFile))
+	timing.phase("typing")
+
+	// The operator has the last word in -interactive mode
+	if opts.Review {
+		if !c.reviewComposed(phoneNumber, opts) {
+			if err := c.clearInput(); err != nil {
+				automessage.Log("warn", fmt.Sprintf("Failed to clear skipped message: %v", err))
+			}
+			return ErrSkippedByOperator
+		}
+		c.lastPreviewScreenshot = c.takeScreenshot(fmt.Sprintf("text_02_text_reviewed_%s.png", cleanNumberForFile))
+	}
+
+	// Sandbox stops right before sending and leaves the chat clean
+	if c.config.Sandbox() {
+		automessage.Log("info", fmt.Sprintf("[SANDBOX] Message composed for %s, not sending", phoneNumber))
+		if err := c.clearInput(); err != nil {
+			automessage.Log("warn", fmt.Sprintf("Failed to clear sandbox message: %v", err))
+		}
+		return nil
+	}
+
+	// Let the recipient see "typing..." for about as long as a person would take
+	if dwell := typingDwell(c.config.Browser.TypingDwellMs, normalizedMessage); dwell > 0 {
+		automessage.Log("debug", fmt.Sprintf("Typing dwell of %v before sending to %s", dwell.Round(100*time.Millisecond), phoneNumber))
+		time.Sleep(dwell)
+		timing.phase("typing_dwell")
+	}
+
+	// Make sure WhatsApp registered the text - otherwise Enter does nothing
+	if !c.ensureSendButtonReady() {
+		c.takeScreenshot(fmt.Sprintf("text_02_send_button_disabled_%s.png", cleanNumberForFile))
+		return fmt.Errorf("send button is not available - WhatsApp did not register the typed text")
+	}
+
+	// Remember the last outgoing bubble, so only a new one can confirm the send
+	lastOutgoingBefore := c.lastOutgoingID()
 
 	// Send the message by pressing Enter (without Shift modifier)
-	Log("debug", "Sending message with Enter key...")
+	automessage.Log("debug", "Sending message with Enter key...")
 	err = chromedp.Run(c.ctx,
 		chromedp.KeyEvent("\r"), // Enter key to send
 	)
 	if err != nil {
 		return fmt.Errorf("failed to send message with Enter key: %w", err)
 	}
+	timing.phase("send")
 
 	// Wait a bit for the message to start sending
 	time.Sleep(3 * time.Second)
 
 	// Verify that a new message was actually sent by checking message count
-	Log("info", "Verifying message was sent...")
-	var messageCountAfter int
-	maxWaitTime := 20 * time.Second
+	automessage.Log("info", "Verifying message was sent...")
+	var messageCountAfter []int
+	maxWaitTime := time.Duration(c.config.Browser.SuccessTimeouts.Bubble) * time.Second
 	checkInterval := 1 * time.Second
 	startTime := time.Now()
 	messageSent := false
 
 	for time.Since(startTime) < maxWaitTime && !messageSent {
-		chromedp.Run(c.ctx,
-			chromedp.Evaluate(`document.querySelectorAll('div[data-pre-plain-text]').length`, &messageCountAfter),
-		)
+		messageCountAfter = c.messageCounts()
 
-		if messageCountAfter > messageCountBefore {
+		if grew := messageCountGrew(messageCountBefore, messageCountAfter); grew >= 0 {
 			messageSent = true
-			Log("info", fmt.Sprintf("✓ New message detected! Count increased from %d to %d", messageCountBefore, messageCountAfter))
+			c.selectors.record("send verification", grew)
+			automessage.Log("info", fmt.Sprintf("✓ New message detected! Count increased from %d to %d (%s)", messageCountBefore[grew], messageCountAfter[grew], c.verifyStrategies()[grew]))
 			break
 		}
 
 		if !messageSent {
-			Log("info", fmt.Sprintf("Waiting for new message to appear... (%v elapsed, count still %d)", time.Since(startTime).Round(time.Second), messageCountAfter))
+			automessage.Log("info", fmt.Sprintf("Waiting for new message to appear... (%v elapsed, count still %v)", time.Since(startTime).Round(time.Second), messageCountAfter))
 			time.Sleep(checkInterval)
 		}
 	}
 
+	// No count grew, but a new last outgoing bubble may still be this message
+	// with its tick, e.g. when the chat dropped older messages as it scrolled
+	if !messageSent && c.newOutgoingMatches(lastOutgoingBefore, message) {
+		messageSent = true
+		c.selectors.record("send verification", len(c.verifyStrategies()))
+		automessage.Log("info", "✓ Message found as a new last outgoing bubble with a tick")
+	}
+
 	if !messageSent {
 		c.takeScreenshot(fmt.Sprintf("text_03_send_failed_%s.png", cleanNumberForFile))
-		Log("error", fmt.Sprintf("Message was NOT sent to %s - message count did not increase after %v", phoneNumber, maxWaitTime))
+
+		// If the input box was cleared by Enter, the message most likely left
+		// even though we couldn't see the bubble - don't report a hard failure
+		var remainingText string
+		chromedp.Run(c.ctx,
+			chromedp.Evaluate(`
+				const input = document.querySelector('div[contenteditable="true"][data-tab="10"]') ||
+				              document.querySelector('div[contenteditable="true"][role="textbox"]');
+				if (!input) { '' }
+				else { input.innerText || input.textContent || '' }
+			`, &remainingText),
+		)
+		if strings.TrimSpace(remainingText) == "" {
+			automessage.Log("warn", fmt.Sprintf("Input box for %s was cleared but no new message appeared after %v - treating as sent-unverified", phoneNumber, maxWaitTime))
+			return ErrSendUnverified
+		}
+
+		if throttled := c.detectThrottle(); throttled != nil {
+			return throttled
+		}
+
+		automessage.Log("error", fmt.Sprintf("Message was NOT sent to %s - message count did not increase after %v", phoneNumber, maxWaitTime))
 		return fmt.Errorf("message was not sent - no new message bubble appeared in chat")
 	}
 
 	c.takeScreenshot(fmt.Sprintf("text_03_message_sent_%s.png", cleanNumberForFile))
 
 	// Wait for checkmark to confirm message is being delivered
-	Log("info", "Waiting for delivery confirmation...")
+	automessage.Log("info", "Waiting for delivery confirmation...")
 	time.Sleep(3 * time.Second)
+	c.trackPendingState(phoneNumber)
+
+	err = c.waitForSuccessCriteria(phoneNumber)
+	timing.phase("verification")
+	if err != nil {
+		return err
+	}
+
+	automessage.Log("info", fmt.Sprintf("Message sent successfully to %s", phoneNumber))
+	return nil
+}
+
+// openChatForSend opens the recipient's chat and waits until it is ready to
+// type into, verifying the recipient when browser.verify_recipient is set
+func (c *WhatsAppClient) openChatForSend(phoneNumber, cleanNumber, chatURL string, opts SendOptions, timing *sendTiming) error {
+	// Disable beforeunload event to prevent "Leave site?" dialog
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(`window.onbeforeunload = null;`, nil),
+	)
+	if err != nil {
+		automessage.Log("warn", fmt.Sprintf("Failed to disable beforeunload: %v", err))
+	}
+
+	// Navigate to chat URL (or search for the chat)
+	if err := c.openChat(chatURL, opts.SearchTerm, 3*time.Second); err != nil {
+		return err
+	}
+	timing.phase("navigation")
+
+	// Wait for chat to fully load and "Starting chat" dialog to disappear
+	automessage.Log("debug", "Waiting for chat to fully load...")
+
+	// Explicitly wait for "Starting chat" spinner/dialog to disappear
+	automessage.Log("info", "Waiting for 'Starting chat' dialog to disappear...")
+	maxStartWait := 15 * time.Second
+	startWaitBegin := time.Now()
+	dialogGone := false
+
+	for time.Since(startWaitBegin) < maxStartWait {
+		var spinnerVisible bool
+		err = chromedp.Run(c.ctx,
+			chromedp.Evaluate(`
+				(function() {
+					// Check for "Starting chat" text or spinner
+					const startingText = Array.from(document.querySelectorAll('div, span')).find(el =>
+						el.textContent.includes('Starting chat') || el.textContent.includes('starting chat')
+					);
+					if (startingText && startingText.offsetParent !== null) return true;
+
+					// Check for loading spinners
+					const spinner = document.querySelector('div[role="progressbar"]');
+					if (spinner && spinner.offsetParent !== null) return true;
+
+					return false;
+				})()
+			`, &spinnerVisible),
+		)
+
+		if err != nil || !spinnerVisible {
+			dialogGone = true
+			automessage.Log("info", "✓ 'Starting chat' dialog is gone")
+			break
+		}
+
+		automessage.Log("debug", fmt.Sprintf("'Starting chat' dialog still visible, waiting... (%v elapsed)", time.Since(startWaitBegin).Round(time.Second)))
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if !dialogGone {
+		automessage.Log("warn", "Timed out waiting for 'Starting chat' dialog to disappear, proceeding anyway...")
+	}
+
+	// Additional wait to ensure UI is stable
+	time.Sleep(1 * time.Second)
+	c.takeScreenshot(fmt.Sprintf("text_01_chat_opened_%s.png", cleanNumber))
+	timing.phase("chat_load")
+
+	if c.config.Browser.ScrollToBottom {
+		c.scrollChatToBottom()
+	}
 
-	Log("info", fmt.Sprintf("Message sent successfully to %s", phoneNumber))
+	if c.config.Browser.VerifyRecipient && !opts.SelfChat {
+		if err := c.verifyRecipient(phoneNumber, opts); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // sendImageWithCaption sends an image with a text caption to a WhatsApp contact
-func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL, message string) error {
-	Log("info", fmt.Sprintf("Sending image with caption to %s", phoneNumber))
+func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL, imagePath, message string, opts SendOptions) error {
+	automessage.Log("info", fmt.Sprintf("Sending image with caption to %s", phoneNumber))
 
 	// Verify image file exists
-	if _, err := os.Stat(c.config.Files.ImagePath); err != nil {
-		return fmt.Errorf("image file not found: %s", c.config.Files.ImagePath)
+	if _, err := os.Stat(imagePath); err != nil {
+		return fmt.Errorf("image file not found: %s", imagePath)
 	}
 
 	// Get absolute path for the image
-	absImagePath, err := filepath.Abs(c.config.Files.ImagePath)
+	absImagePath, err := filepath.Abs(imagePath)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute image path: %w", err)
 	}
-	Log("info", fmt.Sprintf("Using image at: %s", absImagePath))
+	automessage.Log("info", fmt.Sprintf("Using image at: %s", absImagePath))
 
 	// Read the image file into memory
 	imageData, err := os.ReadFile(absImagePath)
 	if err != nil {
 		return fmt.Errorf("failed to read image file: %w", err)
 	}
-	Log("info", fmt.Sprintf("Read image file: %d bytes", len(imageData)))
+	automessage.Log("info", fmt.Sprintf("Read image file: %d bytes", len(imageData)))
 
 	// Navigate to chat
-	Log("info", "Navigating to chat for image send...")
+	automessage.Log("info", "Navigating to chat for image send...")
 	err = chromedp.Run(c.ctx,
 		chromedp.Evaluate(`window.onbeforeunload = null;`, nil),
-		chromedp.Navigate(chatURL),
-		chromedp.Sleep(4*time.Second),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to navigate to chat for image: %w", err)
+		automessage.Log("warn", fmt.Sprintf("Failed to disable beforeunload: %v", err))
+	}
+	if err := c.openChat(chatURL, opts.SearchTerm, 4*time.Second); err != nil {
+		return err
 	}
 
 	// Wait for chat to fully load by checking for message input
-	Log("info", "Waiting for chat to load...")
+	automessage.Log("info", "Waiting for chat to load...")
 	inputSelectors := []string{
 		`//div[@contenteditable='true'][@data-tab='10']`,
 		`//div[@contenteditable='true'][@role='textbox']`,
@@ -628,7 +1230,7 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		cancel()
 		if err == nil {
 			chatLoaded = true
-			Log("info", "Chat loaded successfully")
+			automessage.Log("info", "Chat loaded successfully")
 			break
 		}
 	}
@@ -638,7 +1240,7 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 	}
 
 	// Explicitly wait for "Starting chat" spinner/dialog to disappear
-	Log("info", "Waiting for 'Starting chat' dialog to disappear...")
+	automessage.Log("info", "Waiting for 'Starting chat' dialog to disappear...")
 	maxStartWait := 15 * time.Second
 	startWaitBegin := time.Now()
 	dialogGone := false
@@ -665,23 +1267,38 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 
 		if err != nil || !spinnerVisible {
 			dialogGone = true
-			Log("info", "✓ 'Starting chat' dialog is gone")
+			automessage.Log("info", "✓ 'Starting chat' dialog is gone")
 			break
 		}
 
-		Log("debug", fmt.Sprintf("'Starting chat' dialog still visible, waiting... (%v elapsed)", time.Since(startWaitBegin).Round(time.Second)))
+		automessage.Log("debug", fmt.Sprintf("'Starting chat' dialog still visible, waiting... (%v elapsed)", time.Since(startWaitBegin).Round(time.Second)))
 		time.Sleep(500 * time.Millisecond)
 	}
 
 	if !dialogGone {
-		Log("warn", "Timed out waiting for 'Starting chat' dialog to disappear, proceeding anyway...")
+		automessage.Log("warn", "Timed out waiting for 'Starting chat' dialog to disappear, proceeding anyway...")
 	}
 
 	time.Sleep(1 * time.Second)
 	c.takeScreenshot(fmt.Sprintf("01_chat_loaded_%s.png", cleanNumber))
 
+	if c.config.Browser.ScrollToBottom {
+		c.scrollChatToBottom()
+	}
+
+	if c.config.Browser.VerifyRecipient && !opts.SelfChat {
+		if err := c.verifyRecipient(phoneNumber, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.SkipIfInChat && c.alreadyInChat(message) {
+		automessage.Log("info", fmt.Sprintf("Caption for %s is already in the chat, not sending the image again", phoneNumber))
+		return ErrAlreadyInChat
+	}
+
 	// Close any blocking dialogs (like "Share on WhatsApp" popup)
-	Log("info", "Checking for and closing any blocking dialogs...")
+	automessage.Log("info", "Checking for and closing any blocking dialogs...")
 	closeButtonSelectors := []string{
 		`//button[@aria-label='Close']`,
 		`//div[@role='button'][@aria-label='Close']`,
@@ -693,14 +1310,14 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 	for _, selector := range closeButtonSelectors {
 		err = chromedp.Run(c.ctx, chromedp.Click(selector, chromedp.BySearch))
 		if err == nil {
-			Log("info", "✓ Closed blocking dialog")
+			automessage.Log("info", "✓ Closed blocking dialog")
 			time.Sleep(500 * time.Millisecond)
 			break
 		}
 	}
 
 	// Step 1: Click attachment button first to ensure proper input is available
-	Log("info", "Step 1: Clicking attachment (+) button...")
+	automessage.Log("info", "Step 1: Clicking attachment (+) button...")
 	attachmentSelectors := []string{
 		`//span[@data-icon='plus']`,
 		`//span[@data-icon='plus-rounded']`,
@@ -714,14 +1331,14 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		err = chromedp.Run(c.ctx, chromedp.Click(selector, chromedp.BySearch))
 		if err == nil {
 			attachmentClicked = true
-			Log("info", fmt.Sprintf("✓ Clicked attachment button: %s", selector))
+			automessage.Log("info", fmt.Sprintf("✓ Clicked attachment button: %s", selector))
 			break
 		}
-		Log("debug", fmt.Sprintf("Attachment selector failed: %s", selector))
+		automessage.Log("debug", fmt.Sprintf("Attachment selector failed: %s", selector))
 	}
 
 	if !attachmentClicked {
-		Log("warn", "Could not click attachment button")
+		automessage.Log("warn", "Could not click attachment button")
 		c.takeScreenshot(fmt.Sprintf("02_attachment_not_clicked_%s.png", cleanNumber))
 		return fmt.Errorf("could not click attachment button")
 	}
@@ -729,8 +1346,9 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 	time.Sleep(1 * time.Second)
 	c.takeScreenshot(fmt.Sprintf("02_attachment_menu_%s.png", cleanNumber))
 
-	// Step 2: Click "Photos & Videos" option (2nd item in menu)
-	Log("info", "Step 2: Clicking 'Photos & Videos' menu option...")
+	// Step 2: Click "Photos & Videos" option (2nd item in menu), or
+	// "Document" (1st item) for documents and audio files
+	menuItem := "Photos & Videos"
 	photoVideoSelectors := []string{
 		`//span[contains(text(), 'Photos')]/ancestor::li`,
 		`//li[@data-tab='3']`,
@@ -738,26 +1356,35 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		`//li[contains(@class, 'menu-item')][2]`,
 		`(//ul[@role='menu']//li[@role='menuitem'])[2]`,
 	}
+	if !opts.Attachment.sentAsMedia() {
+		menuItem = "Document"
+		photoVideoSelectors = []string{
+			`//span[contains(text(), 'Document')]/ancestor::li`,
+			`//span[starts-with(@data-icon, 'document')]/ancestor::li`,
+			`(//ul[@role='menu']//li[@role='menuitem'])[1]`,
+		}
+	}
+	automessage.Log("info", fmt.Sprintf("Step 2: Clicking '%s' menu option...", menuItem))
 
 	var photoClicked bool
 	for _, selector := range photoVideoSelectors {
 		err = chromedp.Run(c.ctx, chromedp.Click(selector, chromedp.BySearch))
 		if err == nil {
 			photoClicked = true
-			Log("info", fmt.Sprintf("✓ Clicked Photos & Videos: %s", selector))
+			automessage.Log("info", fmt.Sprintf("✓ Clicked %s: %s", menuItem, selector))
 			break
 		}
-		Log("debug", fmt.Sprintf("Photos selector failed: %s", selector))
+		automessage.Log("debug", fmt.Sprintf("Photos selector failed: %s", selector))
 	}
 
 	if !photoClicked {
-		Log("warn", "Could not click Photos & Videos menu item, trying direct file input...")
+		automessage.Log("warn", fmt.Sprintf("Could not click %s menu item, trying direct file input...", menuItem))
 	}
 
 	time.Sleep(500 * time.Millisecond)
 
 	// Step 3: Find and set file on the Photos/Videos file input
-	Log("info", "Step 3: Finding Photos & Videos file input element...")
+	automessage.Log("info", "Step 3: Finding Photos & Videos file input element...")
 
 	// WhatsApp has multiple file inputs - we need the one for Photos/Videos (not stickers/documents)
 	// The Photos input typically accepts: image/*,video/mp4,video/3gpp,video/quicktime
@@ -766,51 +1393,61 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		`input[type='file'][accept*='video'][accept*='image']`,
 		`input[type='file'][accept*='image/*']`,
 	}
+	if !opts.Attachment.sentAsMedia() {
+		// The Document input takes any file
+		fileInputSelectors = []string{
+			`input[type='file'][accept='*']`,
+			`input[type='file']:not([accept])`,
+			`input[type='file']:not([accept*='image'])`,
+		}
+	}
 
 	var fileInputSet bool
 	var usedSelector string
 	for _, selector := range fileInputSelectors {
-		Log("debug", fmt.Sprintf("Trying file input selector: %s", selector))
+		automessage.Log("debug", fmt.Sprintf("Trying file input selector: %s", selector))
 		err = chromedp.Run(c.ctx,
 			chromedp.SetUploadFiles(selector, []string{absImagePath}, chromedp.ByQuery),
 		)
 		if err == nil {
 			fileInputSet = true
 			usedSelector = selector
-			Log("info", fmt.Sprintf("✓ Set file on input element: %s", selector))
+			automessage.Log("info", fmt.Sprintf("✓ Set file on input element: %s", selector))
 			break
 		}
-		Log("debug", fmt.Sprintf("File input selector failed: %s, error: %v", selector, err))
+		automessage.Log("debug", fmt.Sprintf("File input selector failed: %s, error: %v", selector, err))
 	}
 
 	if !fileInputSet {
 		// Last resort: Use JavaScript to find and trigger file input
-		Log("warn", "Standard file input not found, trying JavaScript approach...")
+		automessage.Log("warn", "Standard file input not found, trying JavaScript approach...")
 		setFileJS := fmt.Sprintf(`
 (function() {
 	const inputs = document.querySelectorAll('input[type="file"]');
 	if (inputs.length === 0) return false;
 
-	// Find the image upload input (usually has accept attribute with image)
+	// Find the image upload input (usually has accept attribute with image),
+	// or for documents the one that isn't
+	const wantImage = %t;
 	for (let input of inputs) {
 		const accept = input.getAttribute('accept') || '';
-		if (accept.includes('image')) {
+		if (accept.includes('image') === wantImage) {
 			input.click();
 			return true;
 		}
 	}
 
-	// If no image-specific input, use first file input
+	// If no matching input, use first file input
 	inputs[0].click();
 	return true;
 })()
-`)
+`, opts.Attachment.sentAsMedia())
 		var clicked bool
 		chromedp.Run(c.ctx, chromedp.Evaluate(setFileJS, &clicked))
 
 		if !clicked {
 			c.takeScreenshot(fmt.Sprintf("02_file_input_not_found_%s.png", cleanNumber))
-			Log("error", "Could not find any file input element")
+			automessage.Log("error", "Could not find any file input element")
 			return fmt.Errorf("could not find file input element for image upload")
 		}
 
@@ -823,7 +1460,7 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 			)
 			if err == nil {
 				fileInputSet = true
-				Log("info", fmt.Sprintf("✓ Set file after JS click: %s", selector))
+				automessage.Log("info", fmt.Sprintf("✓ Set file after JS click: %s", selector))
 				break
 			}
 		}
@@ -834,16 +1471,173 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		}
 	}
 
-	Log("info", fmt.Sprintf("File upload initiated with selector: %s", usedSelector))
+	automessage.Log("info", fmt.Sprintf("File upload initiated with selector: %s", usedSelector))
 	time.Sleep(3 * time.Second)
 
 	// Wait for image preview to appear
-	Log("info", "Waiting for image preview to load...")
-	time.Sleep(2 * time.Second)
-	c.takeScreenshot(fmt.Sprintf("03_image_preview_%s.png", cleanNumber))
+	automessage.Log("info", "Waiting for image preview to load...")
+	if !c.waitForImagePreview(imagePreviewTimeout) {
+		// The attach occasionally doesn't register - attach once more before giving up
+		automessage.Log("warn", fmt.Sprintf("Image preview did not appear within %v, re-attaching image...", imagePreviewTimeout))
+		for _, selector := range fileInputSelectors {
+			err = chromedp.Run(c.ctx,
+				chromedp.SetUploadFiles(selector, []string{absImagePath}, chromedp.ByQuery),
+			)
+			if err == nil {
+				automessage.Log("debug", fmt.Sprintf("Re-attached image with selector: %s", selector))
+				break
+			}
+		}
+
+		if !c.waitForImagePreview(imagePreviewTimeout) {
+			c.takeScreenshot(fmt.Sprintf("03_image_preview_missing_%s.png", cleanNumber))
+			return ErrImagePreviewMissing
+		}
+	}
+	automessage.Log("info", "✓ Image preview is visible")
+	c.lastPreviewScreenshot = c.takeScreenshot(fmt.Sprintf("03_image_preview_%s.png", cleanNumber))
+
+	uploadTimeout := time.Duration(c.config.Browser.VideoUploadTimeoutSeconds) * time.Second
+	if opts.Attachment == AttachmentVideo {
+		c.chooseSendAsVideo()
+		if err := c.waitForVideoUpload(uploadTimeout); err != nil {
+			c.takeScreenshot(fmt.Sprintf("03_video_upload_stuck_%s.png", cleanNumber))
+			chromedp.Run(c.ctx, chromedp.KeyEvent(kb.Escape), chromedp.Sleep(500*time.Millisecond))
+			return err
+		}
+	}
+
+	usedCaptionSelector := c.typeCaption(message)
+
+	c.takeScreenshot(fmt.Sprintf("04_before_send_%s.png", cleanNumber))
+
+	if opts.Review && !c.reviewComposed(phoneNumber, opts) {
+		chromedp.Run(c.ctx, chromedp.KeyEvent(kb.Escape), chromedp.Sleep(500*time.Millisecond))
+		return ErrSkippedByOperator
+	}
+
+	if c.config.Sandbox() {
+		automessage.Log("info", fmt.Sprintf("[SANDBOX] Image composed for %s, discarding preview", phoneNumber))
+		chromedp.Run(c.ctx, chromedp.KeyEvent(kb.Escape), chromedp.Sleep(500*time.Millisecond))
+		return nil
+	}
+
+	// Click the send button in the image preview modal
+	automessage.Log("info", "Looking for send button in image preview...")
+	sendButtonSelectors := []string{
+		`//span[@data-icon='send']`,
+		`//button[@aria-label='Send']`,
+		`//div[@aria-label='Send']`,
+		`//span[@data-icon='send']/ancestor::button`,
+		`//span[@data-icon='send']/parent::div[@role='button']`,
+	}
+
+	// Newer previews send on Enter from the caption, which doesn't depend on
+	// the send button's markup; the button selectors are the fallback. Each
+	// method only runs when the ones before it provably pressed nothing: a
+	// second press could send the image twice.
+	var pressed, triedEnter bool
+	if c.config.Browser.ImageSendVia == "enter" && usedCaptionSelector != "" {
+		automessage.Log("info", "Sending with Enter in the caption...")
+		triedEnter = true
+		pressed = c.sendImageByEnter(usedCaptionSelector)
+	}
+
+	for i, selector := range sendButtonSelectors {
+		if pressed {
+			break
+		}
+		automessage.Log("info", fmt.Sprintf("Trying send button selector %d/%d...", i+1, len(sendButtonSelectors)))
+		ctx, cancel := context.WithTimeout(c.ctx, 3*time.Second)
+		err = chromedp.Run(ctx,
+			chromedp.Click(selector, chromedp.BySearch),
+		)
+		cancel()
+		switch {
+		case err == nil:
+			c.selectors.record("image send button", i)
+			automessage.Log("info", fmt.Sprintf("✓ Clicked send button with selector: %s", selector))
+			pressed = true
+		case errors.Is(err, context.DeadlineExceeded):
+			automessage.Log("debug", fmt.Sprintf("✗ Send button selector %d not found", i+1))
+		default:
+			// The click may have been dispatched before it failed
+			automessage.Log("warn", fmt.Sprintf("Send button click with selector %d failed: %v", i+1, err))
+			pressed = true
+		}
+	}
+
+	// WhatsApp re-renders the button, so the XPath click can miss
+	if !pressed && !triedEnter && usedCaptionSelector != "" {
+		automessage.Log("info", "Send button not found, trying Enter in the caption...")
+		pressed = c.sendImageByEnter(usedCaptionSelector)
+	}
+	if !pressed {
+		pressed = c.sendImageBySyntheticClick(sendButtonSelectors)
+	}
+
+	if !pressed {
+		automessage.Log("error", "Could not find send button in image preview")
+		return fmt.Errorf("could not find send button for image")
+	}
+
+	// Send was pressed: from here on nothing may press it again
+	if !c.waitForImagePreviewClosed(imagePreviewCloseTimeout) {
+		c.takeScreenshot(fmt.Sprintf("05_image_preview_still_open_%s.png", cleanNumber))
+		return fmt.Errorf("%w: image preview still open %v after pressing send", ErrSendUnverified, imagePreviewCloseTimeout)
+	}
+	automessage.Log("info", "✓ Image preview closed after pressing send")
+
+	return c.confirmImageSent(phoneNumber, cleanNumber, opts, uploadTimeout)
+}
+
+// confirmImageSent waits for a sent image to upload and meet the success
+// criteria. Send was already pressed, so every failure is ErrSendUnverified:
+// neither the text fallback nor a retry may send the image again.
+func (c *WhatsAppClient) confirmImageSent(phoneNumber, cleanNumber string, opts SendOptions, uploadTimeout time.Duration) error {
+	// Wait for image to send - give it time for upload and delivery
+	automessage.Log("info", "Waiting for image to upload and send...")
+	time.Sleep(8 * time.Second)
+	if opts.Attachment == AttachmentVideo {
+		if err := c.waitForVideoBubble(uploadTimeout); err != nil {
+			c.takeScreenshot(fmt.Sprintf("05_video_bubble_missing_%s.png", cleanNumber))
+			return err
+		}
+	}
+	c.trackPendingState(phoneNumber)
+
+	if err := c.waitForSuccessCriteria(phoneNumber); err != nil {
+		return err
+	}
+
+	automessage.Log("info", fmt.Sprintf("Image sent successfully to %s", phoneNumber))
+	return nil
+}
+
+// sendVideoWithCaption sends a video through the Photos & Videos file input
+// with message as its caption. It follows the image flow, but waits for the
+// video to finish uploading in the preview before sending, within
+// browser.video_upload_timeout_seconds, and then checks a video bubble
+// appeared. As for images, failures before send is pressed are plain errors
+// and failures after it are ErrSendUnverified.
+func (c *WhatsAppClient) sendVideoWithCaption(phoneNumber, cleanNumber, chatURL, videoPath, message string, opts SendOptions) error {
+	opts.Attachment = AttachmentVideo
+	return c.sendImageWithCaption(phoneNumber, cleanNumber, chatURL, videoPath, message, opts)
+}
+
+// typeCaption types message into the caption input of the open media
+// preview, Shift+Enter between lines, and returns the selector of the
+// caption input; "" when there is no caption or no input was found
+func (c *WhatsAppClient) typeCaption(message string) string {
+	// Without a caption the preview is sent as is, no need to find the input
+	skipCaption := strings.TrimSpace(message) == ""
 
 	// Add caption to the image
-	Log("info", "Adding caption to image...")
+	if skipCaption {
+		automessage.Log("info", "No caption for this image, skipping the caption input")
+	} else {
+		automessage.Log("info", "Adding caption to image...")
+	}
 
 	// Find the caption input box in the image preview modal
 	captionSelectors := []string{
@@ -854,30 +1648,34 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		`//div[@contenteditable='true'][@role='textbox']`,
 	}
 
+	var err error
 	var captionInputFound bool
 	var usedCaptionSelector string
-	for i, selector := range captionSelectors {
-		Log("debug", fmt.Sprintf("Trying caption input selector %d/%d: %s", i+1, len(captionSelectors), selector))
+	if !skipCaption {
+		for i, selector := range captionSelectors {
+			automessage.Log("debug", fmt.Sprintf("Trying caption input selector %d/%d: %s", i+1, len(captionSelectors), selector))
 
-		// Determine if it's XPath or CSS
-		bySearch := strings.HasPrefix(selector, "//") || strings.HasPrefix(selector, "(")
+			// Determine if it's XPath or CSS
+			bySearch := strings.HasPrefix(selector, "//") || strings.HasPrefix(selector, "(")
 
-		ctx, cancel := context.WithTimeout(c.ctx, 2*time.Second)
-		var err error
-		if bySearch {
-			err = chromedp.Run(ctx, chromedp.WaitVisible(selector, chromedp.BySearch))
-		} else {
-			err = chromedp.Run(ctx, chromedp.WaitVisible(selector))
-		}
-		cancel()
+			ctx, cancel := context.WithTimeout(c.ctx, 2*time.Second)
+			var err error
+			if bySearch {
+				err = chromedp.Run(ctx, chromedp.WaitVisible(selector, chromedp.BySearch))
+			} else {
+				err = chromedp.Run(ctx, chromedp.WaitVisible(selector))
+			}
+			cancel()
 
-		if err == nil {
-			captionInputFound = true
-			usedCaptionSelector = selector
-			Log("info", fmt.Sprintf("✓ Found caption input with selector: %s", selector))
-			break
-		} else {
-			Log("debug", fmt.Sprintf("✗ Caption selector %d failed: %v", i+1, err))
+			if err == nil {
+				captionInputFound = true
+				usedCaptionSelector = selector
+				c.selectors.record("caption", i)
+				automessage.Log("info", fmt.Sprintf("✓ Found caption input with selector: %s", selector))
+				break
+			} else {
+				automessage.Log("debug", fmt.Sprintf("✗ Caption selector %d failed: %v", i+1, err))
+			}
 		}
 	}
 
@@ -890,7 +1688,7 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 			err = chromedp.Run(c.ctx, chromedp.Click(usedCaptionSelector))
 		}
 		if err != nil {
-			Log("warn", fmt.Sprintf("Failed to click caption input: %v", err))
+			automessage.Log("warn", fmt.Sprintf("Failed to click caption input: %v", err))
 		}
 
 		time.Sleep(300 * time.Millisecond)
@@ -900,7 +1698,7 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 		normalizedCaption = strings.ReplaceAll(normalizedCaption, "\r", "\n")
 
 		// Type caption with proper newline handling (Shift+Enter for newlines)
-		Log("info", "Typing caption with keyboard simulation...")
+		automessage.Log("info", "Typing caption with keyboard simulation...")
 		captionLines := strings.Split(normalizedCaption, "\n")
 
 		// Remove consecutive empty lines (which cause double spacing)
@@ -927,7 +1725,7 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 					chromedp.Sleep(50*time.Millisecond),
 				)
 				if err != nil {
-					Log("warn", fmt.Sprintf("Failed to send Shift+Enter in caption: %v", err))
+					automessage.Log("warn", fmt.Sprintf("Failed to send Shift+Enter in caption: %v", err))
 					break
 				}
 			}
@@ -946,101 +1744,1104 @@ func (c *WhatsAppClient) sendImageWithCaption(phoneNumber, cleanNumber, chatURL,
 					)
 				}
 				if err != nil {
-					Log("warn", fmt.Sprintf("Failed to type caption line: %v", err))
+					automessage.Log("warn", fmt.Sprintf("Failed to type caption line: %v", err))
 					break
 				}
 			}
 		}
 
-		Log("info", "Caption typing complete")
+		automessage.Log("info", "Caption typing complete")
 		time.Sleep(1 * time.Second)
-	} else {
-		Log("warn", "Could not find caption input - sending image without caption")
+	} else if !skipCaption {
+		automessage.Log("warn", "Could not find caption input - sending image without caption")
 	}
 
-	c.takeScreenshot(fmt.Sprintf("04_before_send_%s.png", cleanNumber))
+	return usedCaptionSelector
+}
 
-	// Click the send button in the image preview modal
-	Log("info", "Looking for send button in image preview...")
-	sendButtonSelectors := []string{
-		`//span[@data-icon='send']`,
-		`//button[@aria-label='Send']`,
-		`//div[@aria-label='Send']`,
-		`//span[@data-icon='send']/ancestor::button`,
-		`//span[@data-icon='send']/parent::div[@role='button']`,
+// openChat opens the chat either by navigating to the send URL or, when a
+// search term is given, through WhatsApp's chat search. settle is how long
+// to wait after navigating.
+func (c *WhatsAppClient) openChat(chatURL, searchTerm string, settle time.Duration) error {
+	if searchTerm != "" {
+		return c.openChatBySearch(searchTerm)
 	}
 
-	var sendClicked bool
-	for i, selector := range sendButtonSelectors {
-		Log("info", fmt.Sprintf("Trying send button selector %d/%d...", i+1, len(sendButtonSelectors)))
+	err := chromedp.Run(c.ctx,
+		chromedp.Navigate(chatURL),
+		chromedp.Sleep(settle), // Wait for navigation
+	)
+	if err != nil {
+		return fmt.Errorf("failed to navigate to chat: %w", err)
+	}
+	return nil
+}
+
+// openChatBySearch types the search term into the chat list's search box
+// and opens the first result. This reaches saved contacts and groups that
+// the send URL can't.
+func (c *WhatsAppClient) openChatBySearch(searchTerm string) error {
+	automessage.Log("info", fmt.Sprintf("Searching for chat %q...", searchTerm))
+
+	ctx, cancel := context.WithTimeout(c.ctx, time.Duration(c.config.Browser.PageLoadTimeout)*time.Second)
+	err := chromedp.Run(ctx, chromedp.WaitVisible(`//div[@id='side']`, chromedp.BySearch))
+	cancel()
+	if err != nil {
+		// Not on the main page (e.g. after an error), load it first
+		if err := c.reloadWhatsApp(); err != nil {
+			return err
+		}
+	}
+
+	searchSelectors := []string{
+		`//div[@id='side']//div[@contenteditable='true']`,
+		`//div[@id='side']//input[@type='text']`,
+	}
+
+	var searchSelector string
+	for _, selector := range searchSelectors {
 		ctx, cancel := context.WithTimeout(c.ctx, 3*time.Second)
-		err = chromedp.Run(ctx,
-			chromedp.Click(selector, chromedp.BySearch),
-		)
+		err := chromedp.Run(ctx, chromedp.Click(selector, chromedp.BySearch))
 		cancel()
 		if err == nil {
-			sendClicked = true
-			Log("info", fmt.Sprintf("✓ Clicked send button with selector: %s", selector))
+			searchSelector = selector
 			break
-		} else {
-			Log("debug", fmt.Sprintf("✗ Send button selector %d failed: %v", i+1, err))
 		}
 	}
-
-	if !sendClicked {
-		Log("error", "Could not find send button in image preview")
-		return fmt.Errorf("could not find send button for image")
+	if searchSelector == "" {
+		return fmt.Errorf("could not find the chat search box")
 	}
 
-	// Wait for image to send - give it time for upload and delivery
-	Log("info", "Waiting for image to upload and send...")
-	time.Sleep(8 * time.Second)
-
-	Log("info", fmt.Sprintf("Image sent successfully to %s", phoneNumber))
-	return nil
-}
-
-// checkNetworkConnectivity verifies we can reach WhatsApp Web
-func checkNetworkConnectivity() error {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-	resp, err := client.Get("https://web.whatsapp.com")
+	// Replace any previous search with this term
+	err = chromedp.Run(c.ctx,
+		chromedp.KeyEvent("a", chromedp.KeyModifiers(2)), // 2 = Cmd/Ctrl modifier
+		chromedp.KeyEvent("\b"),
+		chromedp.SendKeys(searchSelector, searchTerm, chromedp.BySearch),
+		chromedp.Sleep(2*time.Second), // Let the results update
+	)
 	if err != nil {
-		return fmt.Errorf("cannot reach WhatsApp Web: %w", err)
+		return fmt.Errorf("failed to type search term: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 500 {
-		return fmt.Errorf("WhatsApp Web returned server error: %d", resp.StatusCode)
+	resultSelectors := []string{
+		`(//div[@id='pane-side']//div[@role='listitem'])[1]`,
+		`(//div[@id='pane-side']//div[@role='row'])[1]`,
+		`(//div[@id='pane-side']//div[@role='gridcell'])[1]`,
+	}
+	for _, selector := range resultSelectors {
+		ctx, cancel := context.WithTimeout(c.ctx, 3*time.Second)
+		err := chromedp.Run(ctx, chromedp.Click(selector, chromedp.BySearch))
+		cancel()
+		if err == nil {
+			automessage.Log("info", fmt.Sprintf("✓ Opened first search result for %q", searchTerm))
+			chromedp.Run(c.ctx, chromedp.Sleep(1*time.Second))
+			return nil
+		}
 	}
 
-	return nil
+	// Clear the search so the next contact starts from a clean chat list
+	chromedp.Run(c.ctx, chromedp.KeyEvent(kb.Escape))
+	return fmt.Errorf("%w: %q", ErrChatNotFound, searchTerm)
+}
+
+// waitForImagePreviewClosed polls until the preview's send button is gone,
+// meaning the preview closed and the image was sent, for up to timeout
+func (c *WhatsAppClient) waitForImagePreviewClosed(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		var present bool
+		err := chromedp.Run(c.ctx,
+			chromedp.Sleep(250*time.Millisecond),
+			chromedp.Evaluate(`!!(document.querySelector('span[data-icon="send"]') ||
+				document.querySelector('[aria-label="Send"]'))`, &present),
+		)
+		if err == nil && !present {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+	}
+}
+
+// sendImageByEnter focuses the caption input and presses Enter. It reports
+// whether Enter may have been pressed: false only when the caption input
+// couldn't be focused, so nothing was sent.
+func (c *WhatsAppClient) sendImageByEnter(captionSelector string) bool {
+	if captionSelector == "" {
+		return false
+	}
+
+	findCaption := fmt.Sprintf("document.querySelector(%s)", escapeJSString(captionSelector))
+	if strings.HasPrefix(captionSelector, "//") || strings.HasPrefix(captionSelector, "(") {
+		findCaption = fmt.Sprintf("document.evaluate(%s, document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue",
+			escapeJSString(captionSelector))
+	}
+
+	var focused bool
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const caption = %s;
+				if (!caption) return false;
+				caption.focus();
+				return true;
+			})()
+		`, findCaption), &focused),
+	)
+	if err != nil || !focused {
+		automessage.Log("debug", "Caption input not available for Enter")
+		return false
+	}
+
+	if err := chromedp.Run(c.ctx, chromedp.KeyEvent("\r")); err != nil {
+		// The key may have been dispatched before the error
+		automessage.Log("warn", fmt.Sprintf("Pressing Enter in the caption failed: %v", err))
+	} else {
+		automessage.Log("info", "✓ Pressed Enter in the caption")
+	}
+	return true
+}
+
+// sendImageBySyntheticClick finds the send button with the given XPath
+// selectors and dispatches a click on it from JavaScript. It reports whether
+// the click may have been dispatched: false only when no button was found.
+func (c *WhatsAppClient) sendImageBySyntheticClick(selectors []string) bool {
+	automessage.Log("info", "Trying a synthetic click on the send button...")
+
+	var clicked bool
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				for (const selector of %s) {
+					const node = document.evaluate(selector, document, null,
+						XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+					if (!node) continue;
+					const target = node.closest('button, [role="button"]') || node;
+					target.dispatchEvent(new MouseEvent('click', { bubbles: true, cancelable: true, view: window }));
+					return true;
+				}
+				return false;
+			})()
+		`, jsStringArray(selectors)), &clicked),
+	)
+	if err != nil {
+		// The script may have clicked before the error
+		automessage.Log("warn", fmt.Sprintf("Synthetic click on the send button failed: %v", err))
+		return true
+	}
+	if !clicked {
+		automessage.Log("debug", "Send button not found for synthetic click")
+		return false
+	}
+	automessage.Log("info", "✓ Dispatched a synthetic click on the send button")
+	return true
+}
+
+// OwnPhoneNumber reads the logged-in account's phone number from WhatsApp
+// Web's local storage
+func (c *WhatsAppClient) OwnPhoneNumber() (string, error) {
+	var wid string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(`localStorage.getItem('last-wid-md') || localStorage.getItem('last-wid') || ''`, &wid),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to read account id: %w", err)
+	}
+
+	// Stored as e.g. "15551234567:12@c.us", possibly JSON-quoted
+	wid = strings.Trim(wid, `"`)
+	if i := strings.IndexAny(wid, ":@"); i != -1 {
+		wid = wid[:i]
+	}
+	if wid == "" {
+		return "", fmt.Errorf("account phone number not found, set notifications.self_phone")
+	}
+
+	return "+" + wid, nil
+}
+
+// SendToSelf sends a text message to the account's own "Message yourself"
+// chat. If selfPhone is empty the number is detected from the session.
+// It goes through the regular send and its waits; notification timeouts
+// don't apply.
+func (c *WhatsAppClient) SendToSelf(selfPhone, message string) error {
+	selfPhone, err := c.resolveSelfPhone(selfPhone)
+	if err != nil {
+		return err
+	}
+
+	automessage.Log("info", fmt.Sprintf("Sending summary to own number %s", selfPhone))
+	return c.SendMessage(selfPhone, message, SendOptions{TextOnly: true, SelfChat: true})
+}
+
+// PreflightSend sends a sentinel message to the operator's own number and
+// requires WhatsApp to accept it (at least a single tick), proving the
+// session can really send before any contact is messaged
+func (c *WhatsAppClient) PreflightSend(selfPhone string) error {
+	selfPhone, err := c.resolveSelfPhone(selfPhone)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("WhatsApp Automation preflight check %s", time.Now().Format("2006-01-02 15:04:05"))
+	automessage.Log("info", fmt.Sprintf("Preflight: sending a test message to own number %s", selfPhone))
+	if err := c.SendMessage(selfPhone, message, SendOptions{TextOnly: true, SuccessCriteria: "sent", SelfChat: true}); err != nil {
+		return fmt.Errorf("preflight send to %s failed: %w", selfPhone, err)
+	}
+
+	automessage.Log("info", "✓ Preflight message was accepted by WhatsApp")
+	return nil
+}
+
+// resolveSelfPhone returns the configured own number, or the logged-in
+// account's number when none is configured
+func (c *WhatsAppClient) resolveSelfPhone(selfPhone string) (string, error) {
+	if selfPhone != "" {
+		return selfPhone, nil
+	}
+	return c.OwnPhoneNumber()
+}
+
+// detectChatError looks for WhatsApp's "invalid number" dialog. For numbers
+// that pass format validation this means the number isn't registered.
+func (c *WhatsAppClient) detectChatError(phoneNumber string) error {
+	var dialogText string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const needles = %s;
+				const dialog = document.querySelector('div[role="dialog"]') || document.body;
+				const text = dialog.innerText || '';
+				return needles.find(n => text.includes(n)) ? text : '';
+			})()
+		`, jsStringArray(invalidNumberDialogTexts)), &dialogText),
+	)
+	if err != nil || dialogText == "" {
+		return nil
+	}
+
+	automessage.Log("debug", fmt.Sprintf("WhatsApp rejected %s: %s", phoneNumber, strings.TrimSpace(dialogText)))
+	if err := automessage.ValidatePhoneNumber(phoneNumber); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	return fmt.Errorf("%w: %s", ErrNotOnWhatsApp, phoneNumber)
+}
+
+// jsStringArray renders strings as a JavaScript array literal
+func jsStringArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = escapeJSString(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// reloadWhatsApp navigates back to the WhatsApp Web start page and waits
+// for the chat list, clearing any stuck UI state
+func (c *WhatsAppClient) reloadWhatsApp() error {
+	automessage.Log("info", "Reloading WhatsApp Web before retrying...")
+
+	ctx, cancel := context.WithTimeout(c.ctx, time.Duration(c.config.Browser.PageLoadTimeout)*time.Second)
+	defer cancel()
+
+	err := chromedp.Run(ctx,
+		chromedp.Evaluate(`window.onbeforeunload = null;`, nil),
+		chromedp.Navigate(c.config.Browser.WebURL),
+		chromedp.WaitVisible(`//div[@id='side']`, chromedp.BySearch),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reload WhatsApp Web: %w", err)
+	}
+
+	return nil
+}
+
+// rampDelay returns the extra delay before the message with the given
+// zero-based index, see rampDelayFor
+func (c *WhatsAppClient) rampDelay(index int) time.Duration {
+	return rampDelayFor(c.config.RateLimiting.Ramp, index)
+}
+
+// rampDelayFor moves from the initial to the target delay over the
+// configured number of messages. The first message is never delayed.
+func rampDelayFor(ramp automessage.RampConfig, index int) time.Duration {
+	if !ramp.Enabled || index == 0 {
+		return 0
+	}
+
+	progress := float64(index) / float64(ramp.Messages)
+	if progress > 1 {
+		progress = 1
+	}
+	if ramp.Curve == "ease_out" {
+		// Speed up quickly at first, then settle into the target pace
+		progress = 1 - (1-progress)*(1-progress)
+	}
+
+	seconds := ramp.InitialDelaySeconds + (ramp.TargetDelaySeconds-ramp.InitialDelaySeconds)*progress
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// maxTypingDwell caps the typing dwell, so a long message doesn't stall the
+// run for minutes
+const maxTypingDwell = 30 * time.Second
+
+// typingDwell is how long to wait with a composed message before sending:
+// msPerChar for every character, randomly 25% shorter or longer, up to
+// maxTypingDwell. Zero msPerChar disables it.
+func typingDwell(msPerChar int, message string) time.Duration {
+	if msPerChar <= 0 {
+		return 0
+	}
+	base := time.Duration(utf8.RuneCountInString(message)*msPerChar) * time.Millisecond
+	dwell := time.Duration(float64(base) * (0.75 + rand.Float64()*0.5))
+	if dwell > maxTypingDwell {
+		dwell = maxTypingDwell
+	}
+	return dwell
+}
+
+// trackPendingState checks whether the last outgoing message is still showing
+// the pending clock icon and updates the consecutive-pending counter
+func (c *WhatsAppClient) trackPendingState(phoneNumber string) {
+	healthWindow := c.config.RateLimiting.DeliveryHealth.Window
+	if c.config.RateLimiting.BanPendingThreshold <= 0 && healthWindow <= 0 {
+		return
+	}
+
+	var state string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const outgoing = document.querySelectorAll('div.message-out');
+				if (outgoing.length === 0) return '';
+				const last = outgoing[outgoing.length - 1];
+				const has = icons => icons.some(icon => last.querySelector('span[data-icon="' + icon + '"]') !== null);
+				if (has(['msg-time'])) return 'pending';
+				if (has(%s)) return 'delivered';
+				if (has(%s)) return 'sent';
+				return '';
+			})()
+		`, jsStringArray(successTickIcons["delivered"]), jsStringArray(successTickIcons["sent"])), &state),
+	)
+	if err != nil {
+		automessage.Log("debug", fmt.Sprintf("Could not check delivery state for %s: %v", phoneNumber, err))
+		return
+	}
+
+	// Only the clock counts: a single tick just means the recipient's phone
+	// hasn't been online since, which says nothing about blocking
+	if healthWindow > 0 && state != "" {
+		c.deliveryHealth.record(state == "pending", healthWindow)
+	}
+	if c.config.RateLimiting.BanPendingThreshold <= 0 {
+		return
+	}
+
+	if state == "pending" {
+		c.consecutivePending++
+		automessage.Log("warn", fmt.Sprintf("Message to %s is still pending (clock icon) - %d consecutive pending sends",
+			phoneNumber, c.consecutivePending))
+	} else {
+		c.consecutivePending = 0
+	}
+}
+
+// verifyStrategies lists what messageCounts counts: each
+// browser.verify_selectors entry, then the outgoing bubbles
+func (c *WhatsAppClient) verifyStrategies() []string {
+	return append(append([]string{}, c.config.Browser.VerifySelectors...), "div.message-out")
+}
+
+// messageCounts counts the chat's messages with each of verifyStrategies. A
+// selector that doesn't parse counts 0.
+func (c *WhatsAppClient) messageCounts() []int {
+	var counts []int
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			%s.map(selector => {
+				try { return document.querySelectorAll(selector).length; } catch (e) { return 0; }
+			})
+		`, jsStringArray(c.verifyStrategies())), &counts),
+	)
+	return counts
+}
+
+// messageCountGrew returns the index of the first count that is higher after
+// than before, or -1 if none grew
+func messageCountGrew(before, after []int) int {
+	for i := range after {
+		if i < len(before) && after[i] > before[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// isEmptyChat reports whether no strategy counted any message
+func isEmptyChat(counts []int) bool {
+	for _, n := range counts {
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// lastOutgoingID returns the data-id of the chat's last outgoing bubble, ""
+// if there is none or it has no id
+func (c *WhatsAppClient) lastOutgoingID() string {
+	var id string
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				const outgoing = document.querySelectorAll('div.message-out');
+				if (outgoing.length === 0) return '';
+				const last = outgoing[outgoing.length - 1];
+				const row = last.closest('[data-id]') || last.querySelector('[data-id]');
+				return row ? row.getAttribute('data-id') : '';
+			})()
+		`, &id),
+	)
+	return id
+}
+
+// newOutgoingMatches reports whether the last outgoing bubble is a new one,
+// with a data-id other than before, shows a sent or delivered tick and holds
+// exactly message, ignoring differences in whitespace. Emoji count through
+// the alt text of their images.
+func (c *WhatsAppClient) newOutgoingMatches(before, message string) bool {
+	var found bool
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const outgoing = document.querySelectorAll('div.message-out');
+				if (outgoing.length === 0) return false;
+				const last = outgoing[outgoing.length - 1];
+				const row = last.closest('[data-id]') || last.querySelector('[data-id]');
+				const id = row ? row.getAttribute('data-id') : '';
+				if (!id || id === %s) return false;
+				if (!%s.some(icon => last.querySelector('span[data-icon="' + icon + '"]') !== null)) return false;
+
+				const textOf = node => node.nodeType === Node.TEXT_NODE ? node.textContent :
+					node.nodeName === 'IMG' ? (node.getAttribute('alt') || '') :
+					node.nodeName === 'BR' ? '\n' : Array.from(node.childNodes).map(textOf).join('');
+				const normalize = text => text.replace(/\s+/g, ' ').trim();
+				const body = last.querySelector('span.selectable-text') || last.querySelector('[data-pre-plain-text]');
+				return body !== null && normalize(textOf(body)) === normalize(%s);
+			})()
+		`, escapeJSString(before), jsStringArray(successTickIcons["sent"]), escapeJSString(message)), &found),
+	)
+	return found
+}
+
+// successTickIcons are the status icons on the last outgoing message that
+// satisfy each browser.success_criteria beyond "bubble"
+var successTickIcons = map[string][]string{
+	"sent":      {"msg-check", "msg-dblcheck", "msg-dblcheck-ack"},
+	"delivered": {"msg-dblcheck", "msg-dblcheck-ack"},
+}
+
+// waitForSuccessCriteria waits until the last outgoing message shows the tick
+// required by browser.success_criteria. The message has already left the
+// input at this point, so a timeout is reported as ErrSendUnverified rather
+// than a retryable failure.
+func (c *WhatsAppClient) waitForSuccessCriteria(phoneNumber string) error {
+	criteria := c.successCriteria
+	icons, ok := successTickIcons[criteria]
+	if !ok {
+		return nil // "bubble" is already satisfied
+	}
+
+	timeout := time.Duration(c.config.Browser.SuccessTimeouts.Sent) * time.Second
+	if criteria == "delivered" {
+		timeout = time.Duration(c.config.Browser.SuccessTimeouts.Delivered) * time.Second
+	}
+
+	automessage.Log("info", fmt.Sprintf("Waiting up to %v for the message to %s to be %s...", timeout, phoneNumber, criteria))
+	start := time.Now()
+	for time.Since(start) < timeout {
+		var reached bool
+		chromedp.Run(c.ctx,
+			chromedp.Evaluate(fmt.Sprintf(`
+				(function() {
+					const outgoing = document.querySelectorAll('div.message-out');
+					if (outgoing.length === 0) return false;
+					const last = outgoing[outgoing.length - 1];
+					return %s.some(icon => last.querySelector('span[data-icon="' + icon + '"]') !== null);
+				})()
+			`, jsStringArray(icons)), &reached),
+		)
+		if reached {
+			automessage.Log("info", fmt.Sprintf("✓ Message to %s is %s", phoneNumber, criteria))
+			return nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	return fmt.Errorf("%w: not %s within %v", ErrSendUnverified, criteria, timeout)
+}
+
+// pauseIfSoftBanned pauses sending for the configured cooldown when too many
+// consecutive messages stayed pending, which usually means WhatsApp is
+// rate-limiting the account. Unlike aborting, the run resumes afterwards.
+func (c *WhatsAppClient) pauseIfSoftBanned() {
+	threshold := c.config.RateLimiting.BanPendingThreshold
+	if threshold <= 0 || c.consecutivePending < threshold {
+		return
+	}
+
+	cooldown := time.Duration(c.config.RateLimiting.BanCooldownMinutes) * time.Minute
+	automessage.Log("warn", "==========================================================")
+	automessage.Log("warn", fmt.Sprintf("POSSIBLE RATE LIMIT: %d consecutive messages stayed pending", c.consecutivePending))
+	automessage.Log("warn", fmt.Sprintf("Pausing for %v before resuming (resumes at %s)",
+		cooldown, time.Now().Add(cooldown).Format("15:04:05")))
+	automessage.Log("warn", "==========================================================")
+	time.Sleep(cooldown)
+
+	automessage.Log("info", "Cooldown finished, resuming sending")
+	c.consecutivePending = 0
+}
+
+// sendButtonReady reports whether the send button is present and enabled
+func (c *WhatsAppClient) sendButtonReady() bool {
+	var ready bool
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				const icon = document.querySelector('span[data-icon="send"]');
+				if (!icon) return false;
+				const button = icon.closest('button, div[role="button"]');
+				if (!button) return true;
+				return !button.disabled && button.getAttribute('aria-disabled') !== 'true';
+			})()
+		`, &ready),
+	)
+	return err == nil && ready
+}
+
+// waitForInputText polls the input box until it holds as many characters as
+// the message (ignoring whitespace, which the editor reshapes into line
+// breaks), then waits the configured pre-send delay. On slow machines
+// WhatsApp can lag behind the typing, and pressing Enter early sends an empty
+// or partial message. Gives up quietly after pre_send_wait_seconds; the
+// caller's own verification decides what to do with a short input.
+func (c *WhatsAppClient) waitForInputText(expected string) {
+	want := utf8.RuneCountInString(stripWhitespace(expected))
+	timeout := time.Duration(c.config.Browser.PreSendWaitSeconds) * time.Second
+	deadline := time.Now().Add(timeout)
+
+	got := 0
+	for {
+		var text string
+		chromedp.Run(c.ctx,
+			chromedp.Evaluate(`
+				(function() {
+					const input = document.querySelector('div[contenteditable="true"][data-tab="10"]') ||
+					              document.querySelector('div[contenteditable="true"][role="textbox"]');
+					if (!input) return '';
+					// Emoji are rendered as images, their text lives in alt
+					let text = input.textContent || '';
+					input.querySelectorAll('img[alt]').forEach(img => { text += img.alt; });
+					return text;
+				})()
+			`, &text),
+		)
+		got = utf8.RuneCountInString(stripWhitespace(text))
+		if got >= want || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if got < want {
+		automessage.Log("warn", fmt.Sprintf("Input box has %d of %d characters after %v, continuing anyway", got, want, timeout))
+	} else {
+		automessage.Log("debug", fmt.Sprintf("Input box holds all %d characters", want))
+	}
+
+	if c.config.Browser.PreSendDelayMs > 0 {
+		time.Sleep(time.Duration(c.config.Browser.PreSendDelayMs) * time.Millisecond)
+	}
+}
+
+// stripWhitespace removes all whitespace from s
+func stripWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// alreadyInChatPrefix is how many characters of the message, after
+// whitespace normalization, must match the start of an outgoing message
+const alreadyInChatPrefix = 200
+
+// alreadyInChat reports whether one of the last browser.already_in_chat_scan
+// outgoing messages in the open chat starts with the message. Whitespace is
+// normalized on both sides, and long messages only need to match their first
+// alreadyInChatPrefix characters, since WhatsApp may collapse long bubbles
+// behind "Read more".
+func (c *WhatsAppClient) alreadyInChat(message string) bool {
+	want := []rune(strings.Join(strings.Fields(message), " "))
+	if len(want) == 0 {
+		return false
+	}
+	if len(want) > alreadyInChatPrefix {
+		want = want[:alreadyInChatPrefix]
+	}
+
+	var texts []string
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const bubbles = Array.from(document.querySelectorAll('div.message-out'));
+				return bubbles.slice(-%d).map(bubble => {
+					const body = bubble.querySelector('[data-pre-plain-text]') || bubble;
+					return body.innerText || '';
+				});
+			})()
+		`, c.config.Browser.AlreadyInChatScan), &texts),
+	)
+	if err != nil {
+		automessage.Log("warn", fmt.Sprintf("Could not scan the chat for earlier messages: %v", err))
+		return false
+	}
+
+	for _, text := range texts {
+		if strings.HasPrefix(strings.Join(strings.Fields(text), " "), string(want)) {
+			return true
+		}
+	}
+	automessage.Log("debug", fmt.Sprintf("Message not among the last %d outgoing messages", len(texts)))
+	return false
+}
+
+// reviewComposed pauses with the message composed in the browser and asks
+// the operator whether to send it. After "e" the operator edits the text in
+// Chrome and is asked again; true means send whatever is there now.
+func (c *WhatsAppClient) reviewComposed(phoneNumber string, opts SendOptions) bool {
+	label := phoneNumber
+	if opts.Recipient != "" {
+		label = fmt.Sprintf("%s (%s)", opts.Recipient, phoneNumber)
+	}
+
+	edited := false
+	for {
+		switch promptReview(label, edited) {
+		case reviewSend:
+			if edited {
+				// Give WhatsApp a moment to register edits before sending
+				time.Sleep(500 * time.Millisecond)
+			}
+			return true
+		case reviewEdit:
+			edited = true
+		default:
+			automessage.Log("info", fmt.Sprintf("Operator skipped %s", label))
+			return false
+		}
+	}
+}
+
+// scrollChatToBottom scrolls the open chat's message pane to the bottom
+// browser.scroll_to_bottom_count times, pausing in between, so lazily loaded
+// recent messages and the input settle before anything is counted or typed
+func (c *WhatsAppClient) scrollChatToBottom() {
+	for i := 0; i < c.config.Browser.ScrollToBottomCount; i++ {
+		var scrolled bool
+		err := chromedp.Run(c.ctx,
+			chromedp.Evaluate(`
+				(function() {
+					// The message pane is the tallest scrollable element in the chat
+					const panes = Array.from(document.querySelectorAll('#main div')).filter(el => {
+						const overflow = getComputedStyle(el).overflowY;
+						return (overflow === 'auto' || overflow === 'scroll') && el.scrollHeight > el.clientHeight;
+					});
+					if (panes.length === 0) return false;
+					panes.sort((a, b) => b.scrollHeight - a.scrollHeight);
+					panes[0].scrollTop = panes[0].scrollHeight;
+					return true;
+				})()
+			`, &scrolled),
+			chromedp.Sleep(400*time.Millisecond),
+		)
+		if err != nil {
+			automessage.Log("debug", fmt.Sprintf("Scroll to bottom failed: %v", err))
+			return
+		}
+		if !scrolled {
+			automessage.Log("debug", "No scrollable message pane, chat is short enough already")
+			return
+		}
+	}
+	automessage.Log("debug", fmt.Sprintf("Scrolled chat to bottom %d times", c.config.Browser.ScrollToBottomCount))
+}
+
+// ensureSendButtonReady checks the send button before pressing Enter. If it is
+// missing, it nudges WhatsApp's input handler with an input event and then a
+// typed space/backspace before giving up.
+func (c *WhatsAppClient) ensureSendButtonReady() bool {
+	if c.sendButtonReady() {
+		return true
+	}
+
+	automessage.Log("warn", "Send button not available, re-triggering input event...")
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				const input = document.querySelector('div[contenteditable="true"][data-tab="10"]') ||
+				              document.querySelector('div[contenteditable="true"][role="textbox"]');
+				if (!input) return;
+				input.focus();
+				input.dispatchEvent(new InputEvent('input', { bubbles: true }));
+			})()
+		`, nil),
+		chromedp.Sleep(500*time.Millisecond),
+	)
+	if c.sendButtonReady() {
+		automessage.Log("info", "✓ Send button available after input event")
+		return true
+	}
+
+	automessage.Log("warn", "Send button still not available, retyping a character...")
+	chromedp.Run(c.ctx,
+		chromedp.KeyEvent(" "),
+		chromedp.Sleep(100*time.Millisecond),
+		chromedp.KeyEvent("\b"),
+		chromedp.Sleep(500*time.Millisecond),
+	)
+	if c.sendButtonReady() {
+		automessage.Log("info", "✓ Send button available after retyping")
+		return true
+	}
+
+	return false
+}
+
+// quoteLastInbound opens the context menu of the last incoming message and
+// picks Reply, so the message typed next is sent as a quoted reply. Returns
+// false, leaving the chat as it was, if there is nothing to quote or the
+// menu can't be used.
+func (c *WhatsAppClient) quoteLastInbound() bool {
+	// Mark the last inbound bubble and find where to hover over it
+	var box struct {
+		Found bool    `json:"found"`
+		X     float64 `json:"x"`
+		Y     float64 `json:"y"`
+	}
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				document.querySelectorAll('[data-quote-target]').forEach(el => el.removeAttribute('data-quote-target'));
+				const incoming = document.querySelectorAll('div.message-in');
+				if (incoming.length === 0) return { found: false, x: 0, y: 0 };
+				const last = incoming[incoming.length - 1];
+				last.scrollIntoView({ block: 'center' });
+				last.setAttribute('data-quote-target', '1');
+				const rect = last.getBoundingClientRect();
+				return { found: true, x: rect.left + rect.width / 2, y: rect.top + rect.height / 2 };
+			})()
+		`, &box),
+	)
+	if err != nil || !box.Found {
+		automessage.Log("info", "No inbound message to quote, sending normally")
+		return false
+	}
+
+	// The menu arrow only appears while the mouse is over the bubble
+	chromedp.Run(c.ctx,
+		chromedp.MouseEvent(input.MouseMoved, box.X, box.Y),
+		chromedp.Sleep(500*time.Millisecond),
+	)
+
+	menuOpened := false
+	for _, selector := range quoteMenuArrowSelectors {
+		ctx, cancel := context.WithTimeout(c.ctx, 2*time.Second)
+		err := chromedp.Run(ctx, chromedp.Click(`[data-quote-target='1'] `+selector, chromedp.ByQuery))
+		cancel()
+		if err == nil {
+			menuOpened = true
+			break
+		}
+	}
+	if !menuOpened {
+		automessage.Log("warn", "Could not open the message menu to quote, sending normally")
+		return false
+	}
+
+	for _, selector := range quoteReplySelectors {
+		ctx, cancel := context.WithTimeout(c.ctx, 2*time.Second)
+		err := chromedp.Run(ctx, chromedp.Click(selector, chromedp.BySearch))
+		cancel()
+		if err == nil {
+			automessage.Log("info", "✓ Quoting the last inbound message")
+			time.Sleep(300 * time.Millisecond)
+			return true
+		}
+	}
+
+	automessage.Log("warn", "Reply option not found in the message menu, sending normally")
+	chromedp.Run(c.ctx, chromedp.KeyEvent(kb.Escape))
+	return false
+}
+
+// inputFocused reports whether the element matched by the XPath selector
+// (or one of its descendants) currently has keyboard focus
+func (c *WhatsAppClient) inputFocused(selector string) bool {
+	var focused bool
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const input = document.evaluate(%s, document, null,
+					XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+				const active = document.activeElement;
+				return !!input && !!active && (active === input || input.contains(active));
+			})()
+		`, escapeJSString(selector)), &focused),
+	)
+	return err == nil && focused
+}
+
+// ensureInputFocused verifies the message input has focus after clicking it,
+// retrying the click and then falling back to a JS focus() call
+func (c *WhatsAppClient) ensureInputFocused(selector string) error {
+	if c.inputFocused(selector) {
+		return nil
+	}
+
+	automessage.Log("warn", "Message input did not take focus after click, clicking again...")
+	chromedp.Run(c.ctx,
+		chromedp.Click(selector, chromedp.BySearch),
+		chromedp.Sleep(300*time.Millisecond),
+	)
+	if c.inputFocused(selector) {
+		automessage.Log("info", "✓ Message input focused after second click")
+		return nil
+	}
+
+	automessage.Log("warn", "Message input still not focused, focusing via JavaScript...")
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(fmt.Sprintf(`
+			(function() {
+				const input = document.evaluate(%s, document, null,
+					XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+				if (input) input.focus();
+			})()
+		`, escapeJSString(selector)), nil),
+		chromedp.Sleep(200*time.Millisecond),
+	)
+	if c.inputFocused(selector) {
+		automessage.Log("info", "✓ Message input focused via JavaScript")
+		return nil
+	}
+
+	return fmt.Errorf("message input box could not be focused (an overlay may be covering it)")
+}
+
+// clearInput empties the focused message input box using the configured
+// browser.clear_strategy
+func (c *WhatsAppClient) clearInput() error {
+	if c.config.Browser.ClearStrategy == "keyboard" {
+		// Select all and delete
+		// Use Cmd+A on Mac, Ctrl+A on other systems
+		return chromedp.Run(c.ctx,
+			chromedp.KeyEvent("a", chromedp.KeyModifiers(2)), // 2 = Cmd/Ctrl modifier
+			chromedp.Sleep(100*time.Millisecond),
+			chromedp.KeyEvent("\b"),
+			chromedp.Sleep(300*time.Millisecond),
+		)
+	}
+
+	// DOM strategy: empty the contenteditable directly and let WhatsApp know
+	var cleared bool
+	err := chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				const input = document.querySelector('div[contenteditable="true"][data-tab="10"]') ||
+				              document.querySelector('div[contenteditable="true"][role="textbox"]');
+				if (!input) return false;
+				input.innerHTML = '';
+				input.focus();
+				input.dispatchEvent(new InputEvent('input', { bubbles: true }));
+				return true;
+			})()
+		`, &cleared),
+		chromedp.Sleep(300*time.Millisecond),
+	)
+	if err != nil {
+		return err
+	}
+	if !cleared {
+		return fmt.Errorf("message input box not found")
+	}
+	return nil
+}
+
+// waitForImagePreview waits until the media preview modal shows the attached
+// image, returning false if it doesn't appear within the timeout
+func (c *WhatsAppClient) waitForImagePreview(timeout time.Duration) bool {
+	previewSelectors := []string{
+		`//div[@role='dialog']//img[starts-with(@src, 'blob:')]`,
+		`//img[starts-with(@src, 'blob:')]`,
+		`//video[starts-with(@src, 'blob:')]`,
+		`//div[@role='dialog']//span[@data-icon='media-play']`,
+		`//div[@role='dialog']//span[starts-with(@data-icon, 'document')]`,
+		`//span[@data-icon='x-viewer']`,
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, selector := range previewSelectors {
+			var nodes []*cdp.Node
+			ctx, cancel := context.WithTimeout(c.ctx, 1*time.Second)
+			err := chromedp.Run(ctx, chromedp.Nodes(selector, &nodes, chromedp.BySearch, chromedp.AtLeast(0)))
+			cancel()
+			if err == nil && len(nodes) > 0 {
+				automessage.Log("debug", fmt.Sprintf("Image preview detected with selector: %s", selector))
+				return true
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return false
+}
+
+// chooseSendAsVideo picks "Video" when the preview asks how to send the
+// file (WhatsApp offers GIF for short clips). Nothing happens if the choice
+// isn't shown.
+func (c *WhatsAppClient) chooseSendAsVideo() {
+	var chose bool
+	chromedp.Run(c.ctx,
+		chromedp.Evaluate(`
+			(function() {
+				const dialog = document.querySelector('div[role="dialog"]') || document;
+				const option = Array.from(dialog.querySelectorAll('[role="radio"], [role="button"], button, label'))
+					.find(el => el.textContent.trim() === 'Video' && el.offsetParent !== null);
+				if (!option || option.getAttribute('aria-checked') === 'true') return false;
+				option.click();
+				return true;
+			})()
+		`, &chose),
+	)
+	if chose {
+		automessage.Log("info", "✓ Chose to send the file as a video")
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// waitForVideoUpload waits until the preview stops showing upload progress.
+// WhatsApp processes the whole video before it can be sent, which takes a
+// while for large files.
+func (c *WhatsAppClient) waitForVideoUpload(timeout time.Duration) error {
+	automessage.Log("info", fmt.Sprintf("Waiting up to %v for the video to finish uploading...", timeout))
+	start := time.Now()
+	for time.Since(start) < timeout {
+		var uploading bool
+		err := chromedp.Run(c.ctx,
+			chromedp.Evaluate(`
+				(function() {
+					const dialog = document.querySelector('div[role="dialog"]') || document;
+					const progress = dialog.querySelector('[role="progressbar"], span[data-icon="media-cancel"]');
+					return progress !== null && progress.offsetParent !== null;
+				})()
+			`, &uploading),
+		)
+		if err == nil && !uploading {
+			automessage.Log("info", fmt.Sprintf("✓ Video ready to send (%v)", time.Since(start).Round(time.Second)))
+			return nil
+		}
+		automessage.Log("debug", fmt.Sprintf("Video still uploading... (%v elapsed)", time.Since(start).Round(time.Second)))
+		time.Sleep(1 * time.Second)
+	}
+	return fmt.Errorf("video did not finish uploading within %v (raise browser.video_upload_timeout_seconds for large files)", timeout)
+}
+
+// waitForVideoBubble waits for the last outgoing message to be a video that
+// is done uploading. It runs after send was pressed, so a bubble that never
+// shows up or is still uploading at the timeout is ErrSendUnverified.
+func (c *WhatsAppClient) waitForVideoBubble(timeout time.Duration) error {
+	start := time.Now()
+	var state string
+	for time.Since(start) < timeout {
+		chromedp.Run(c.ctx,
+			chromedp.Evaluate(`
+				(function() {
+					const outgoing = document.querySelectorAll('div.message-out');
+					if (outgoing.length === 0) return 'none';
+					const last = outgoing[outgoing.length - 1];
+					const video = last.querySelector('video, span[data-icon="media-play"], span[data-icon="video-pip"], span[data-icon="msg-video"]');
+					if (!video) return 'none';
+					if (last.querySelector('[role="progressbar"], span[data-icon="media-cancel"]')) return 'uploading';
+					return 'sent';
+				})()
+			`, &state),
+		)
+		if state == "sent" {
+			automessage.Log("info", "✓ Video bubble is in the chat")
+			return nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+	if state == "uploading" {
+		return fmt.Errorf("%w: video was still uploading after %v", ErrSendUnverified, timeout)
+	}
+	return fmt.Errorf("%w: no video bubble within %v", ErrSendUnverified, timeout)
+}
+
+// checkNetworkConnectivity verifies we can reach WhatsApp Web, going through
+// the given proxy if one is configured for the browser
+func checkNetworkConnectivity(proxyServer string) error {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+	if proxyServer != "" {
+		proxyURL, err := url.Parse(proxyServer)
+		if err != nil {
+			return fmt.Errorf("invalid proxy server %q: %w", proxyServer, err)
+		}
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	}
+	resp, err := client.Get("https://web.whatsapp.com")
+	if err != nil {
+		return fmt.Errorf("cannot reach WhatsApp Web: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("WhatsApp Web returned server error: %d", resp.StatusCode)
+	}
+
+	return nil
 }
 
 // ensureUserDataDir creates the user data directory if it doesn't exist
 // and ensures it has proper permissions for Chrome to access
 func ensureUserDataDir(dirPath string) error {
 	if dirPath == "" {
-		Log("info", "No user data directory specified, Chrome will use default")
+		automessage.Log("info", "No user data directory specified, Chrome will use default")
 		return nil
 	}
 
 	// dirPath is already absolute from LoadConfig
-	Log("info", fmt.Sprintf("Using user data directory: %s", dirPath))
+	automessage.Log("info", fmt.Sprintf("Using user data directory: %s", dirPath))
 
 	// Check if directory exists
 	info, err := os.Stat(dirPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Directory doesn't exist, create it
-			Log("info", fmt.Sprintf("Creating user data directory: %s", dirPath))
+			automessage.Log("info", fmt.Sprintf("Creating user data directory: %s", dirPath))
 			// Use 0777 permissions for Windows compatibility
 			if err := os.MkdirAll(dirPath, 0777); err != nil {
-				Log("error", fmt.Sprintf("Failed to create directory: %v", err))
+				automessage.Log("error", fmt.Sprintf("Failed to create directory: %v", err))
 				return fmt.Errorf("failed to create directory %s: %w\nTry running as administrator or use a different directory", dirPath, err)
 			}
-			Log("info", "User data directory created successfully")
+			automessage.Log("info", "User data directory created successfully")
 		} else {
 			return fmt.Errorf("failed to check directory: %w", err)
 		}
@@ -1049,17 +2850,17 @@ func ensureUserDataDir(dirPath string) error {
 		if !info.IsDir() {
 			return fmt.Errorf("path exists but is not a directory: %s", dirPath)
 		}
-		Log("debug", "User data directory already exists")
+		automessage.Log("debug", "User data directory already exists")
 	}
 
 	// Test write permissions by creating a test file
 	testFile := filepath.Join(dirPath, ".write_test")
 	if err := os.WriteFile(testFile, []byte("test"), 0666); err != nil {
-		Log("error", fmt.Sprintf("Cannot write to directory: %v", err))
+		automessage.Log("error", fmt.Sprintf("Cannot write to directory: %v", err))
 		return fmt.Errorf("directory exists but is not writable: %s\nTry:\n1. Running as administrator\n2. Deleting the directory and trying again\n3. Using a different directory in config.yaml", dirPath)
 	}
 	os.Remove(testFile) // Clean up test file
-	Log("debug", "Directory write test passed")
+	automessage.Log("debug", "Directory write test passed")
 
 	return nil
 }
@@ -1079,21 +2880,48 @@ func escapeJSString(s string) string {
 }
 
 // takeScreenshot captures a screenshot and saves it to the screenshots directory
-func (c *WhatsAppClient) takeScreenshot(filename string) {
-	screenshotDir := "screenshots"
+// (under files.output_dir when set).
+// It returns the saved path, or an empty string if the screenshot failed.
+func (c *WhatsAppClient) takeScreenshot(filename string) string {
+	screenshotDir := c.config.OutputPath("screenshots")
 	os.MkdirAll(screenshotDir, 0755)
 
 	screenshotPath := filepath.Join(screenshotDir, filename)
 	var buf []byte
 	if err := chromedp.Run(c.ctx, chromedp.FullScreenshot(&buf, 100)); err != nil {
-		Log("warn", fmt.Sprintf("Failed to take screenshot %s: %v", filename, err))
-		return
+		automessage.Log("warn", fmt.Sprintf("Failed to take screenshot %s: %v", filename, err))
+		return ""
 	}
 
 	if err := os.WriteFile(screenshotPath, buf, 0644); err != nil {
-		Log("warn", fmt.Sprintf("Failed to save screenshot %s: %v", filename, err))
-		return
+		automessage.Log("warn", fmt.Sprintf("Failed to save screenshot %s: %v", filename, err))
+		return ""
 	}
 
-	Log("info", fmt.Sprintf("📸 Screenshot saved: %s", screenshotPath))
+	automessage.Log("info", fmt.Sprintf("📸 Screenshot saved: %s", screenshotPath))
+	return screenshotPath
+}
+
+// SelectorReport summarizes which fallback selectors matched over the run,
+// with warnings for primary selectors that look stale
+func (c *WhatsAppClient) SelectorReport() (lines []string, stale []string) {
+	return c.selectors.report()
+}
+
+// PhaseAverages returns the average duration of each send phase over the
+// run so far
+func (c *WhatsAppClient) PhaseAverages() []phaseTiming {
+	return c.timings.averages()
+}
+
+// LastSendHadAttachment reports whether the most recent SendMessage sent its
+// image or attachment rather than falling back to text
+func (c *WhatsAppClient) LastSendHadAttachment() bool {
+	return c.lastAttachmentSent
+}
+
+// LastPreviewScreenshot returns the screenshot of the composed message
+// (text ready or image preview) taken during the most recent SendMessage
+func (c *WhatsAppClient) LastPreviewScreenshot() string {
+	return c.lastPreviewScreenshot
 }
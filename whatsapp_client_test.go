@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestBuildChatURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		phone string
+		want  string
+	}{
+		{"default base", "https://web.whatsapp.com/send", "+1 (510) 216-8856", "https://web.whatsapp.com/send?phone=15102168856"},
+		{"custom base", "http://127.0.0.1:8080/send", "+15102168856", "http://127.0.0.1:8080/send?phone=15102168856"},
+		{"leading 00", "https://web.whatsapp.com/send", "00972-54-123-4567", "https://web.whatsapp.com/send?phone=972541234567"},
+		{"unicode digits", "https://web.whatsapp.com/send", "+٩٧٢ ٥٤ ١٢٣ ٤٥٦٧", "https://web.whatsapp.com/send?phone=972541234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildChatURL(tt.base, tt.phone); got != tt.want {
+				t.Errorf("buildChatURL(%q, %q) = %q, want %q", tt.base, tt.phone, got, tt.want)
+			}
+		})
+	}
+}